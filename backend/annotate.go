@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/geniusdynamics/updater/backend/pkg/updater"
+)
+
+// rdjsonDiagnostic is a diagnostic in the reviewdog rdjsonl format
+type rdjsonDiagnostic struct {
+	Message  string `json:"message"`
+	Location struct {
+		Path  string `json:"path"`
+		Range struct {
+			Start struct {
+				Line int `json:"line"`
+			} `json:"start"`
+		} `json:"range"`
+	} `json:"location"`
+	Severity string `json:"severity"`
+	Source   struct {
+		Name string `json:"name"`
+	} `json:"source"`
+}
+
+func runAnnotate(args []string) {
+	flags := flag.NewFlagSet("annotate", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text prints the annotated file, rdjsonl emits reviewdog diagnostics")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: annotate [flags] file")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 || (*format != "text" && *format != "rdjsonl") {
+		flags.Usage()
+		os.Exit(2)
+	}
+	fileName := flags.Arg(0)
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, _, _, u := setup()
+	deps, err := u.CheckContent(ctx, fileName, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	byLine := map[int][]updater.Dependency{}
+	for _, d := range deps {
+		n := dependencyLine(lines, d)
+		byLine[n] = append(byLine[n], d)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	if *format == "rdjsonl" {
+		enc := json.NewEncoder(w)
+		for _, n := range slices.Sorted(maps.Keys(byLine)) {
+			for _, d := range byLine[n] {
+				var diag rdjsonDiagnostic
+				diag.Message = annotation(d)
+				diag.Location.Path = fileName
+				diag.Location.Range.Start.Line = n
+				diag.Severity = "INFO"
+				if d.Outdated() || d.Error != "" {
+					diag.Severity = "WARNING"
+				}
+				diag.Source.Name = "ns8-updater"
+				if err := enc.Encode(diag); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+		return
+	}
+	for i, line := range lines {
+		var notes bytes.Buffer
+		for _, d := range byLine[i+1] {
+			notes.WriteString("  # <- " + annotation(d))
+		}
+		fmt.Fprintf(w, "%s%s\n", line, notes.String())
+	}
+}
+
+// dependencyLine returns the 1-based line of d, the first line mentioning
+// its image when the scan didn't record it
+func dependencyLine(lines []string, d updater.Dependency) int {
+	if d.Line > 0 {
+		return d.Line
+	}
+	_, repo, _ := strings.Cut(d.Image, "/")
+	for i, line := range lines {
+		if strings.Contains(line, repo) {
+			return i + 1
+		}
+	}
+	return 1
+}
+
+func annotation(d updater.Dependency) string {
+	switch d.Status() {
+	case "outdated", "held", "unpinned":
+		return fmt.Sprintf("%s %s: %s -> %s", d.Image, d.Status(), d.Current, d.Latest)
+	case "error", "unsupported":
+		return fmt.Sprintf("%s %s: %s", d.Image, d.Status(), d.Error)
+	default:
+		return fmt.Sprintf("%s:%s %s", d.Image, d.Current, d.Status())
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+// runExportCatalog checks the repositories like scan and writes the targets
+// found to a catalog for scan -offline -catalog on an air-gapped machine
+func runExportCatalog(args []string) {
+	flags := flag.NewFlagSet("export-catalog", flag.ExitOnError)
+	output := flags.String("o", "catalog.json", "file to write the catalog to")
+	group := flags.String("group", "", "only check repositories of this configured group")
+	_ = flags.Parse(args)
+
+	ctx, draining, cfg, u := setup()
+	dependencies, _, failed := checkRepositories(ctx, draining, cfg, u, *group, "", false)
+	if err := writeBundle(ctx, u, *output, dependencies); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote the catalog of %d dependencies to %s", len(dependencies), *output)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+// runDoctor checks the setup and prints how to fix what is wrong, it exits
+// with status 1 when a check fails
+func runDoctor(args []string) {
+	ctx, _, _, u := setup()
+	failed := false
+	for _, c := range u.Doctor(ctx) {
+		switch {
+		case c.Problem == "":
+			fmt.Printf("ok    %s\n", c.Name)
+		case c.Warning:
+			fmt.Printf("warn  %s: %s\n      %s\n", c.Name, c.Problem, c.Remedy)
+		default:
+			fmt.Printf("FAIL  %s: %s\n      %s\n", c.Name, c.Problem, c.Remedy)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runConfig runs the config sub-commands, only validate for now
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "validate" {
+		log.Fatal("usage: config validate")
+	}
+	if err := config.CheckFile(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("config file is valid")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/geniusdynamics/updater/backend/internal/files"
+)
+
+func runHook(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "install":
+			runHookInstall(args[1:])
+			return
+		case "check":
+			runHookCheck(args[1:])
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, "usage: hook install [flags] | hook check [flags]")
+	os.Exit(2)
+}
+
+// runHookInstall writes a git hook running hook check into a repository
+func runHookInstall(args []string) {
+	flags := flag.NewFlagSet("hook install", flag.ExitOnError)
+	dir := flags.String("dir", ".", "repository to install the hook in")
+	block := flags.Bool("block", false, "make the commit fail instead of only warning")
+	prePush := flags.Bool("pre-push", false, "install a pre-push hook instead of a pre-commit one")
+	_ = flags.Parse(args)
+
+	out, err := exec.Command("git", "-C", *dir, "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		log.Fatalf("%s is not a git repository: %s", *dir, err)
+	}
+	hooks := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(hooks) {
+		hooks = filepath.Join(*dir, hooks)
+	}
+	name := "pre-commit"
+	if *prePush {
+		name = "pre-push"
+	}
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	command := fmt.Sprintf("%q hook check", self)
+	if *block {
+		command += " -block"
+	}
+	if *prePush {
+		command += " -all"
+	}
+	if err := os.MkdirAll(hooks, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	hook := filepath.Join(hooks, name)
+	if _, err := os.Stat(hook); err == nil {
+		log.Fatalf("%s already exists, add this line to it instead: exec %s", hook, command)
+	}
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexec "+command+"\n"), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Installed %s", hook)
+}
+
+// runHookCheck reports the problematic pins in the staged scanned files, or
+// in every scanned file with -all
+func runHookCheck(args []string) {
+	flags := flag.NewFlagSet("hook check", flag.ExitOnError)
+	block := flags.Bool("block", false, "exit with an error when a problem is found")
+	all := flags.Bool("all", false, "check every scanned file instead of the staged ones")
+	_ = flags.Parse(args)
+
+	_, _, cfg, u := setup()
+	gitArgs := []string{"diff", "--cached", "--name-only", "--diff-filter=ACMR"}
+	if *all {
+		gitArgs = []string{"ls-files"}
+	}
+	out, err := exec.Command("git", gitArgs...).Output()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	problems := 0
+	for _, p := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if p == "" || !files.MatchesName(u.FileNames, filepath.Base(p)) || files.InIgnoredDir(cfg.IgnoreDirs, p) {
+			continue
+		}
+		data, err := exec.Command("git", "show", ":"+p).Output()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, image := range u.ScanContent(p, data) {
+			for _, problem := range u.PinProblems(image) {
+				fmt.Fprintf(os.Stderr, "%s: %s:%s: %s\n", p, image.Name(), image.Tag, problem)
+				problems++
+			}
+		}
+	}
+	if problems > 0 && *block {
+		os.Exit(1)
+	}
+}
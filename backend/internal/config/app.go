@@ -0,0 +1,128 @@
+package config
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// TokenSource returns the token authenticating GitHub API calls and clones
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a personal access token, empty for anonymous access
+type StaticToken string
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// AppTokenSource returns installation tokens of a GitHub App, renewed
+// shortly before they expire
+type AppTokenSource struct {
+	client         *http.Client
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewAppTokenSource returns the token source of the installation of the app,
+// authenticated with the PEM private key in keyFile
+func NewAppTokenSource(client *http.Client, appID, installationID int64, keyFile string) (*AppTokenSource, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading GitHub App key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM key found in %s", keyFile)
+	}
+	var key *rsa.PrivateKey
+	if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub App key %s: %w", keyFile, err)
+		}
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("GitHub App key %s is not an RSA key", keyFile)
+		}
+	}
+	return &AppTokenSource{client: client, appID: appID, installationID: installationID, key: key}, nil
+}
+
+func (s *AppTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expires) > 5*time.Minute {
+		return s.token, nil
+	}
+
+	jwt, err := s.jwt()
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("https://api.github.com/app/installations/%d/access_tokens", s.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error creating GitHub App installation token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("error creating GitHub App installation token: %s", resp.Status)
+	}
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid GitHub App installation token: %w", err)
+	}
+	if body.Token == "" {
+		return "", errors.New("invalid GitHub App installation token: empty token")
+	}
+	s.token, s.expires = body.Token, body.ExpiresAt
+	return s.token, nil
+}
+
+// jwt returns the token authenticating as the app, valid for a few minutes
+// and backdated against clock drift
+func (s *AppTokenSource) jwt() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.appID,
+	})
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(nil, s.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing GitHub App token: %w", err)
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUpstreamUnavailable is returned without contacting the endpoint while its
+// circuit breaker is open
+var ErrUpstreamUnavailable = errors.New("upstream unavailable")
+
+// CircuitBreaker is a RoundTripper that tracks consecutive failures per host.
+// Once a host reaches Threshold failures every request to it fails fast with
+// ErrUpstreamUnavailable for Cooldown, after which a single probe request is
+// let through to decide whether to close the breaker again.
+type CircuitBreaker struct {
+	Base      http.RoundTripper
+	Threshold int
+	Cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*breakerState
+	// now is the clock, replaced in tests
+	now func() time.Time
+}
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewCircuitBreaker(base http.RoundTripper, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Base:      base,
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		hosts:     map[string]*breakerState{},
+		now:       time.Now,
+	}
+}
+
+func (b *CircuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if b.Threshold <= 0 {
+		return b.Base.RoundTrip(req)
+	}
+	host := req.URL.Host
+	if err := b.allow(host); err != nil {
+		return nil, err
+	}
+
+	resp, err := b.Base.RoundTrip(req)
+	// a caller giving up is not the upstream's fault
+	if err != nil && req.Context().Err() != nil {
+		b.release(host)
+		return resp, err
+	}
+	b.record(host, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	return resp, err
+}
+
+func (b *CircuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.hosts[host]
+	if !ok {
+		st = &breakerState{}
+		b.hosts[host] = st
+	}
+	if st.failures < b.Threshold {
+		return nil
+	}
+	if wait := st.openUntil.Sub(b.now()); wait > 0 {
+		return fmt.Errorf("%w: %s, retrying in %s", ErrUpstreamUnavailable, host, wait.Round(time.Second))
+	}
+	// half-open, only one probe at a time
+	if st.probing {
+		return fmt.Errorf("%w: %s, waiting for probe request", ErrUpstreamUnavailable, host)
+	}
+	st.probing = true
+	return nil
+}
+
+func (b *CircuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hosts[host].probing = false
+}
+
+func (b *CircuitBreaker) record(host string, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := b.hosts[host]
+	st.probing = false
+	if !failed {
+		st.failures = 0
+		return
+	}
+	st.failures++
+	if st.failures >= b.Threshold {
+		st.openUntil = b.now().Add(b.Cooldown)
+	}
+}
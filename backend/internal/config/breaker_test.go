@@ -0,0 +1,210 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// fakeClock is a clock moved forward by the tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestBreaker returns a breaker with a threshold of 2 failures and a
+// cooldown of a minute over base, driven by clock
+func newTestBreaker(base http.RoundTripper, clock *fakeClock) *CircuitBreaker {
+	b := NewCircuitBreaker(base, 2, time.Minute)
+	b.now = clock.Now
+	return b
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	// step sends a request to host after the clock moved by after, the
+	// upstream answers with status, 0 being a network error, unless the
+	// caller cancelled it. sent tells whether the request must reach the
+	// upstream rather than fail fast.
+	type step struct {
+		after  time.Duration
+		host   string
+		status int
+		cancel bool
+		sent   bool
+	}
+	const ok, failing = http.StatusOK, http.StatusBadGateway
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"opens at the threshold", []step{
+			{status: failing, sent: true},
+			{status: 0, sent: true},
+			{status: ok, sent: false},
+			{after: 59 * time.Second, status: ok, sent: false},
+		}},
+		{"success resets the count", []step{
+			{status: failing, sent: true},
+			{status: ok, sent: true},
+			{status: failing, sent: true},
+			{status: ok, sent: true},
+		}},
+		{"client errors are not failures", []step{
+			{status: http.StatusNotFound, sent: true},
+			{status: http.StatusTooManyRequests, sent: true},
+			{status: ok, sent: true},
+		}},
+		{"successful probe closes", []step{
+			{status: failing, sent: true},
+			{status: failing, sent: true},
+			{after: time.Minute, status: ok, sent: true},
+			{status: failing, sent: true},
+			{status: ok, sent: true},
+		}},
+		{"failed probe reopens", []step{
+			{status: failing, sent: true},
+			{status: failing, sent: true},
+			{after: time.Minute, status: failing, sent: true},
+			{after: 30 * time.Second, status: ok, sent: false},
+			{after: 30 * time.Second, status: ok, sent: true},
+		}},
+		{"hosts trip independently", []step{
+			{host: "ghcr.io", status: failing, sent: true},
+			{host: "ghcr.io", status: failing, sent: true},
+			{host: "quay.io", status: ok, sent: true},
+			{host: "ghcr.io", status: ok, sent: false},
+		}},
+		{"cancelled requests are not failures", []step{
+			{status: failing, sent: true},
+			{status: 0, cancel: true, sent: true},
+			{status: ok, sent: true},
+		}},
+		{"cancelled probe lets another one through", []step{
+			{status: failing, sent: true},
+			{status: failing, sent: true},
+			{after: time.Minute, status: 0, cancel: true, sent: true},
+			{status: ok, sent: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			var status int
+			sent := false
+			b := newTestBreaker(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				sent = true
+				if err := req.Context().Err(); err != nil {
+					return nil, err
+				}
+				if status == 0 {
+					return nil, errors.New("connection reset")
+				}
+				return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+			}), clock)
+			for i, s := range tt.steps {
+				clock.Advance(s.after)
+				host := s.host
+				if host == "" {
+					host = "registry-1.docker.io"
+				}
+				ctx, cancel := context.WithCancel(context.Background())
+				if s.cancel {
+					cancel()
+				}
+				req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+host+"/v2/", nil)
+				status, sent = s.status, false
+				_, err := b.RoundTrip(req)
+				cancel()
+				if sent != s.sent {
+					t.Fatalf("step %d: sent %v, want %v", i, sent, s.sent)
+				}
+				if !s.sent && !errors.Is(err, ErrUpstreamUnavailable) {
+					t.Fatalf("step %d: got %v, want ErrUpstreamUnavailable", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var calls atomic.Int32
+	release := make(chan struct{})
+	failing := true
+	b := newTestBreaker(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		if failing {
+			return nil, errors.New("connection refused")
+		}
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), clock)
+	get := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "https://ghcr.io/v2/", nil)
+		_, err := b.RoundTrip(req)
+		return err
+	}
+	for range 2 {
+		_ = get()
+	}
+	failing = false
+	clock.Advance(time.Minute)
+	calls.Store(0)
+
+	// every request of the half-open breaker but the probe fails fast
+	const n = 10
+	errs := make(chan error, n)
+	for range n {
+		go func() { errs <- get() }()
+	}
+	for range n - 1 {
+		if err := <-errs; !errors.Is(err, ErrUpstreamUnavailable) {
+			t.Fatalf("got %v, want ErrUpstreamUnavailable", err)
+		}
+	}
+	close(release)
+	if err := <-errs; err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	if c := calls.Load(); c != 1 {
+		t.Fatalf("%d requests reached the upstream, want a single probe", c)
+	}
+
+	// closed again, concurrent requests all go through
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := get(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if c := calls.Load(); c != n+1 {
+		t.Errorf("%d requests reached the upstream, want %d", c, n+1)
+	}
+}
@@ -2,37 +2,436 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/files"
 )
 
+// Version of the updater, overridden at build time with
+// -ldflags "-X github.com/geniusdynamics/updater/backend/internal/config.Version=..."
+var Version = "dev"
+
+// DefaultUserAgent identifies our traffic to registries and forges
+var DefaultUserAgent = fmt.Sprintf("ns8-updater/%s (+https://github.com/geniusdynamics/updater)", Version)
+
 type Config struct {
-	GithubAPIKey    string
-	GitHubClient    *http.Client
+	GithubAPIKey string
+	// GitHubTokens authenticates API calls and clones, with GithubAPIKey or
+	// the installation tokens of the configured GitHub App
+	GitHubTokens TokenSource
+	GitHubClient *http.Client
+	// GitSSHKey is the path of the private key cloning over SSH, empty
+	// clones over HTTPS
+	GitSSHKey  string
+	HttpClient *http.Client
+	// Transport is the circuit breaker shared by every client
+	Transport       http.RoundTripper
 	UserName        string
 	Organization    *string
 	TemporaryFolder string
+	UserAgent       string
+	// CloneDepth limits cloned history, 0 clones everything
+	CloneDepth int
+	// RepositoryConcurrency is the number of repositories checked at once
+	RepositoryConcurrency int
+	// GitHubPageSize is the number of repositories listed per page
+	GitHubPageSize int
+	// ScanTimeout and ScanMaxFiles bound the scan of one repository
+	ScanTimeout  time.Duration
+	ScanMaxFiles int
+	// ScanMaxFileSize skips matching files above this many bytes
+	ScanMaxFileSize int64
+	Debug           bool
+	// Registries lists private or self-hosted registries to check besides
+	// the built-in ones
+	Registries []RegistryConfig
+	// DockerHubMaxPages, DockerHubMaxTags and DockerHubConcurrency bound the
+	// tag listing of Docker Hub images, 0 means unlimited
+	DockerHubMaxPages    int
+	DockerHubMaxTags     int
+	DockerHubConcurrency int
+	// DockerHubPageSize and DockerHubOrdering tune the Docker Hub pages
+	DockerHubPageSize int
+	DockerHubOrdering string
+	// LookupTimeout bounds the registry calls made to check one image
+	LookupTimeout time.Duration
+	// TagCandidates stops listing tags once this many newer versions were
+	// found, 0 lists every page
+	TagCandidates int
+	// CompareDigests resolves the digest of tags like latest that have no
+	// comparable version, their content changed when it differs from the
+	// pinned digest or the one saved in ScanState
+	CompareDigests bool
+	// CheckModules looks up the latest published module image of every
+	// repository
+	CheckModules bool
+	// CheckSignatures reports whether proposed tags are signed
+	CheckSignatures bool
+	// Platforms lists the platforms, e.g. linux/amd64, proposed tags must
+	// be published for, empty skips the check
+	Platforms []string
+	// RequireSigned lists image patterns for which only signed tags are
+	// proposed
+	RequireSigned []string
+	// Verify lists images whose proposed tags must pass cosign verification
+	Verify []VerifyConfig
+	// Variants overrides the tag flavor extraction of matching images
+	Variants []VariantConfig
+	// Schemes selects the version scheme of matching images
+	Schemes []SchemeConfig
+	// Streams keeps pins of matching images within their version stream
+	Streams []StreamConfig
+	// CheckEOL looks up the end of life of current versions on
+	// endoflife.date, for the images mapped to a product in EOL
+	CheckEOL bool
+	EOL      []EOLConfig
+	// EOLWarning reports current versions reaching their end of life within
+	// this duration as eol_soon, 0 disables it
+	EOLWarning time.Duration
+	// Quarantine lists known bad versions, extended with the shared list at
+	// QuarantineURL
+	Quarantine    []QuarantineConfig
+	QuarantineURL string
+	// Repositories assigns groups and excluded images to repositories
+	Repositories []RepositoryConfig
+	// RepoSelectors, when set, selects the repositories by topic or name
+	// pattern
+	RepoSelectors RepoSelectors
+	// IncludeArchived, IncludeForks and IncludeTemplates select archived,
+	// forked and template repositories, which are skipped by default
+	IncludeArchived  bool
+	IncludeForks     bool
+	IncludeTemplates bool
+	// ExcludeImages lists image patterns that are never checked
+	ExcludeImages []string
+	// ScanPatterns are the base names or glob patterns of the scanned files
+	ScanPatterns []string
+	// IgnoreDirs are the names or glob patterns of the directories scans
+	// don't descend into
+	IgnoreDirs []string
+	// Holds pins images or freezes their updates until a date
+	Holds []HoldConfig
+	// Owners lists the organizations and users to scan, when empty
+	// Organization or UserName is scanned
+	Owners []OwnerConfig
+	// TelemetryURL receives anonymous usage statistics after each scan, empty
+	// disables telemetry
+	TelemetryURL string
+	// ScanState is the report the next scan -since-last compares against
+	ScanState string
+	// TokenExpiryWarning warns when a GitHub token expires within this
+	// duration, 0 disables the warning
+	TokenExpiryWarning time.Duration
+
+	// env holds the variables set by the selected profile
+	env environ
+}
+
+// environ is the process environment overlaid with the variables of a
+// profile, which are never exported to the process
+type environ map[string]string
+
+func (e environ) lookup(key string) (string, bool) {
+	if value, ok := e[key]; ok {
+		return value, true
+	}
+	return os.LookupEnv(key)
+}
+
+// Getenv returns the variable named key, as set by the selected profile or
+// the process environment
+func (c *Config) Getenv(key string) string {
+	value, _ := c.env.lookup(key)
+	return value
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
+func (e environ) getEnv(key, fallback string) string {
+	if value, ok := e.lookup(key); ok {
 		return value
 	}
 	return fallback
 }
 
-func NewConfig() *Config {
-	token := getEnv("GITHUB_TOKEN", "")
-	org := getEnv("GITHUB_ORGANIZATION", "")
-	tempFolder := getEnv("TEMPORARY_FOLDER", "/tmp/ns8-updater/")
+func (e environ) getEnvInt(key string, fallback int) int {
+	value, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid value for %s: %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return i
+}
+
+func (e environ) getEnvBool(key string, fallback bool) bool {
+	value, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("invalid value for %s: %q, using %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+func (e environ) getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := e.lookup(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid value for %s: %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getEnvList splits a comma separated variable, skipping empty items
+func (e environ) getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(e.getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// configFile returns the path of the configuration file, config.json unless
+// CONFIG_FILE is set
+func configFile() string {
+	if fileName := os.Getenv("CONFIG_FILE"); fileName != "" {
+		return fileName
+	}
+	return "config.json"
+}
+
+// NewConfig reads the configuration from the environment and CONFIG_FILE,
+// with the profile named by NS8_UPDATER_PROFILE applied. A missing file
+// without profile yields the defaults.
+func NewConfig() (*Config, error) {
+	// the profile sets environment variables, load it before reading them
+	fileCfg, err := LoadFile(configFile(), os.Getenv("NS8_UPDATER_PROFILE"))
+	if err != nil {
+		return nil, err
+	}
+	env := fileCfg.env
+	token := env.getEnv("GITHUB_TOKEN", "")
+	org := env.getEnv("GITHUB_ORGANIZATION", "")
+	tempFolder := env.getEnv("TEMPORARY_FOLDER", "/tmp/ns8-updater/")
+	userAgent := env.getEnv("USER_AGENT", DefaultUserAgent)
 	_ = checkTempDirExists(tempFolder)
+	// one breaker shared by every client so GitHub, Docker Hub, GHCR and Quay
+	// each trip independently by host, a request retried on transient errors
+	// only counts once
+	endpoints := fileCfg.TLS
+	for _, r := range fileCfg.Registries {
+		if e, ok := r.Endpoint(); ok {
+			endpoints = append(endpoints, e)
+		}
+	}
+	for _, host := range env.getEnvList("INSECURE_HOSTS") {
+		endpoints = append(endpoints, TLSConfig{Host: host, InsecureSkipVerify: true})
+	}
+	conn := Connection{
+		Timeout:             env.getEnvDuration("HTTP_TIMEOUT", 30*time.Second),
+		CloneTimeout:        env.getEnvDuration("CLONE_TIMEOUT", 10*time.Minute),
+		MaxConnsPerHost:     env.getEnvInt("HTTP_MAX_CONNS_PER_HOST", 0),
+		MaxIdleConnsPerHost: env.getEnvInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 10),
+		IdleConnTimeout:     env.getEnvDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+	}
+	base, err := NewBaseTransport(env.getEnv("CA_BUNDLE", ""), conn, endpoints)
+	if err != nil {
+		return nil, err
+	}
+	retry := NewRetry(
+		base,
+		env.getEnvInt("RETRY_ATTEMPTS", 3),
+		env.getEnvDuration("RETRY_DELAY", 500*time.Millisecond),
+		env.getEnvDuration("RETRY_MAX_DELAY", 30*time.Second),
+	)
+	breaker := NewCircuitBreaker(
+		retry,
+		env.getEnvInt("BREAKER_THRESHOLD", 5),
+		env.getEnvDuration("BREAKER_COOLDOWN", time.Minute),
+	)
+	var tokens TokenSource = StaticToken(token)
+	if appID := int64(env.getEnvInt("GITHUB_APP_ID", 0)); appID != 0 {
+		app, err := NewAppTokenSource(
+			NewPlainHttpClient(breaker, userAgent),
+			appID,
+			int64(env.getEnvInt("GITHUB_APP_INSTALLATION_ID", 0)),
+			env.getEnv("GITHUB_APP_PRIVATE_KEY", ""),
+		)
+		if err != nil {
+			return nil, err
+		}
+		tokens = app
+	}
 	return &Config{
-		GithubAPIKey:    token,
-		GitHubClient:    NewHttpClient(token),
-		UserName:        getEnv("GITHUB_USERNAME", ""),
-		Organization:    &org,
-		TemporaryFolder: tempFolder,
+		GithubAPIKey:          token,
+		GitHubTokens:          tokens,
+		GitHubClient:          NewHttpClient(breaker, tokens, userAgent),
+		GitSSHKey:             env.getEnv("GIT_SSH_KEY", ""),
+		HttpClient:            NewPlainHttpClient(breaker, userAgent),
+		Transport:             breaker,
+		UserName:              env.getEnv("GITHUB_USERNAME", ""),
+		Organization:          &org,
+		TemporaryFolder:       tempFolder,
+		UserAgent:             userAgent,
+		CloneDepth:            env.getEnvInt("CLONE_DEPTH", 1),
+		RepositoryConcurrency: env.getEnvInt("REPOSITORY_CONCURRENCY", 4),
+		GitHubPageSize:        env.getEnvInt("GITHUB_PAGE_SIZE", 100),
+		ScanTimeout:           env.getEnvDuration("SCAN_TIMEOUT", 2*time.Minute),
+		ScanMaxFiles:          env.getEnvInt("SCAN_MAX_FILES", 50000),
+		ScanMaxFileSize:       int64(env.getEnvInt("SCAN_MAX_FILE_SIZE", 1<<20)),
+		Debug:                 env.getEnvBool("DEBUG", false),
+		Registries:            fileCfg.Registries,
+		DockerHubMaxPages:     env.getEnvInt("DOCKERHUB_MAX_PAGES", 10),
+		DockerHubMaxTags:      env.getEnvInt("DOCKERHUB_MAX_TAGS", 0),
+		DockerHubConcurrency:  env.getEnvInt("DOCKERHUB_CONCURRENCY", 4),
+		DockerHubPageSize:     env.getEnvInt("DOCKERHUB_PAGE_SIZE", 100),
+		DockerHubOrdering:     env.getEnv("DOCKERHUB_ORDERING", ""),
+		TagCandidates:         env.getEnvInt("TAG_CANDIDATES", 0),
+		LookupTimeout:         env.getEnvDuration("LOOKUP_TIMEOUT", 2*time.Minute),
+		CompareDigests:        env.getEnvBool("COMPARE_DIGESTS", false),
+		CheckModules:          env.getEnvBool("CHECK_MODULES", true),
+		CheckSignatures:       env.getEnvBool("CHECK_SIGNATURES", false),
+		Platforms:             env.getEnvList("PLATFORMS"),
+		RequireSigned:         fileCfg.RequireSigned,
+		Verify:                fileCfg.Verify,
+		Variants:              fileCfg.Variants,
+		Schemes:               fileCfg.Schemes,
+		Streams:               fileCfg.Streams,
+		CheckEOL:              env.getEnvBool("CHECK_EOL", false),
+		EOL:                   fileCfg.EOL,
+		EOLWarning:            env.getEnvDuration("EOL_WARNING", 90*24*time.Hour),
+		Quarantine:            fileCfg.Quarantine,
+		QuarantineURL:         env.getEnv("QUARANTINE_URL", fileCfg.QuarantineURL),
+		Repositories:          fileCfg.Repositories,
+		RepoSelectors:         fileCfg.RepoSelectors,
+		IncludeArchived:       env.getEnvBool("INCLUDE_ARCHIVED", false),
+		IncludeForks:          env.getEnvBool("INCLUDE_FORKS", false),
+		IncludeTemplates:      env.getEnvBool("INCLUDE_TEMPLATES", false),
+		ExcludeImages:         fileCfg.ExcludeImages,
+		ScanPatterns:          env.scanPatterns(fileCfg.ScanPatterns),
+		IgnoreDirs:            env.ignoreDirs(fileCfg.IgnoreDirs),
+		Holds:                 fileCfg.Holds,
+		Owners:                fileCfg.Owners,
+		TelemetryURL:          env.getEnv("TELEMETRY_URL", ""),
+		ScanState:             env.getEnv("SCAN_STATE", filepath.Join(tempFolder, "last-scan.json")),
+		TokenExpiryWarning:    env.getEnvDuration("TOKEN_EXPIRY_WARNING", 14*24*time.Hour),
+		env:                   env,
+	}, nil
+}
+
+// scanPatterns returns SCAN_PATTERNS when set, configured otherwise, falling
+// back to build-images.sh
+func (e environ) scanPatterns(configured []string) []string {
+	if patterns := e.getEnvList("SCAN_PATTERNS"); len(patterns) > 0 {
+		return patterns
+	}
+	if len(configured) > 0 {
+		return configured
+	}
+	return []string{"build-images.sh"}
+}
+
+// ignoreDirs returns IGNORE_DIRS when set, configured otherwise, falling back
+// to files.DefaultIgnoreDirs
+func (e environ) ignoreDirs(configured []string) []string {
+	if dirs := e.getEnvList("IGNORE_DIRS"); len(dirs) > 0 {
+		return dirs
+	}
+	if configured != nil {
+		return configured
+	}
+	return files.DefaultIgnoreDirs
+}
+
+// MatchesScanPattern reports whether a file named name is scanned
+func (c *Config) MatchesScanPattern(name string) bool {
+	for _, pattern := range c.ScanPatterns {
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RepositoryGroups returns the groups of the repository named name, in
+// configuration order and without duplicates
+func (c *Config) RepositoryGroups(name string) []string {
+	var groups []string
+	for _, r := range c.Repositories {
+		if ok, _ := path.Match(r.Name, name); !ok {
+			continue
+		}
+		for _, g := range r.Groups {
+			if !slices.Contains(groups, g) {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups
+}
+
+// InGroup reports whether the repository named name belongs to group
+func (c *Config) InGroup(name, group string) bool {
+	return slices.Contains(c.RepositoryGroups(name), group)
+}
+
+// RequiresSignature reports whether only signed tags may be proposed for
+// image
+func (c *Config) RequiresSignature(image string) bool {
+	for _, pattern := range c.RequireSigned {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludesImage reports whether image is excluded globally or in the
+// repository named repository
+func (c *Config) ExcludesImage(repository, image string) bool {
+	patterns := slices.Clone(c.ExcludeImages)
+	for _, r := range c.Repositories {
+		if ok, _ := path.Match(r.Name, repository); ok {
+			patterns = append(patterns, r.ExcludeImages...)
+		}
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Hold returns the first hold applying to image pinned to version in the
+// repository named repository
+func (c *Config) Hold(repository, image, version string) (HoldConfig, bool) {
+	now := time.Now()
+	for _, h := range c.Holds {
+		if h.Matches(repository, image, version, now) {
+			return h, true
+		}
 	}
+	return HoldConfig{}, false
 }
 
 func checkTempDirExists(dir string) error {
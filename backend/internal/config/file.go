@@ -0,0 +1,403 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FileConfig holds the settings that don't fit in environment variables. It is
+// read from CONFIG_FILE (config.json by default) when the file exists.
+type FileConfig struct {
+	Registries []RegistryConfig `json:"registries"`
+	// RequireSigned lists image patterns, e.g. ghcr.io/nethserver/*, for which
+	// only signed tags are proposed
+	RequireSigned []string `json:"require_signed"`
+	// Verify lists images whose proposed tags must carry a valid cosign
+	// signature
+	Verify []VerifyConfig `json:"verify"`
+	// Variants overrides how the flavor of a tag, e.g. alpine, is extracted
+	Variants []VariantConfig `json:"variants"`
+	// Schemes selects how the tags of matching images are compared
+	Schemes []SchemeConfig `json:"schemes"`
+	// Streams lists the supported version streams of images pinned to
+	// several versions, e.g. postgres 15 and 16
+	Streams []StreamConfig `json:"streams"`
+	// Quarantine lists known bad versions that are never proposed, more are
+	// fetched from QuarantineURL when set
+	Quarantine    []QuarantineConfig `json:"quarantine"`
+	QuarantineURL string             `json:"quarantine_url,omitempty"`
+	// Repositories assigns groups to repositories, scans and reports can be
+	// restricted to a group
+	Repositories []RepositoryConfig `json:"repositories"`
+	// Owners lists the GitHub organizations and users to scan
+	Owners []OwnerConfig `json:"owners"`
+	// RepoSelectors discovers repositories by topic or name pattern instead
+	// of searching for the ns8- prefix
+	RepoSelectors RepoSelectors `json:"repo_selectors"`
+	// ExcludeImages lists image patterns, e.g. docker.io/library/postgres,
+	// that are never checked
+	ExcludeImages []string `json:"exclude_images"`
+	// Holds pins images or freezes their updates until a date, the updates
+	// are still reported but marked as held
+	Holds []HoldConfig `json:"holds"`
+	// EOL maps images to their endoflife.date product, besides the built-in
+	// ones like postgres or php
+	EOL []EOLConfig `json:"eol"`
+	// Profiles are named variants of this configuration, e.g. prod and
+	// test, selected with NS8_UPDATER_PROFILE or -profile, see ProfileConfig
+	Profiles map[string]json.RawMessage `json:"profiles"`
+	// ScanPatterns are the base names, or glob patterns like
+	// *.containerfile, of the scanned files, build-images.sh when empty
+	ScanPatterns []string `json:"scan_patterns"`
+	// IgnoreDirs are the names, or glob patterns, of the directories scans
+	// don't descend into. When absent .git, node_modules, vendor and
+	// testdata are skipped, an empty list skips none.
+	IgnoreDirs []string `json:"ignore_dirs"`
+	// TLS overrides the TLS settings of other endpoints than registries,
+	// e.g. GitHub Enterprise
+	TLS []TLSConfig `json:"tls"`
+
+	// env holds the variables set by the applied profile
+	env environ
+}
+
+// HoldConfig holds the updates of the images matching Image in the
+// repositories matching Repository, both default to every one. With Version
+// the hold only applies while the image is pinned to that version, with
+// Until it ends on that date (YYYY-MM-DD).
+type HoldConfig struct {
+	Repository string `json:"repository,omitempty"`
+	Image      string `json:"image,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Until      string `json:"until,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// Matches reports whether h holds the image pinned to version in the
+// repository named repository at now
+func (h HoldConfig) Matches(repository, image, version string, now time.Time) bool {
+	for _, m := range []struct{ pattern, name string }{{h.Repository, repository}, {h.Image, image}} {
+		if ok, _ := path.Match(m.pattern, m.name); m.pattern != "" && !ok {
+			return false
+		}
+	}
+	if h.Version != "" && h.Version != version {
+		return false
+	}
+	if h.Until != "" {
+		until, err := time.Parse(time.DateOnly, h.Until)
+		if err != nil || !now.Before(until) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the hold, e.g. "until 2025-01-15: release freeze"
+func (h HoldConfig) String() string {
+	s := "pinned"
+	if h.Until != "" {
+		s = "until " + h.Until
+	}
+	if h.Reason != "" {
+		s += ": " + h.Reason
+	}
+	return s
+}
+
+// RepositoryConfig assigns groups, e.g. collaboration or critical, to the
+// repositories whose name matches Name
+type RepositoryConfig struct {
+	// Name is a pattern like ns8-nextcloud or ns8-*
+	Name   string   `json:"name"`
+	Groups []string `json:"groups"`
+	// ExcludeImages lists image patterns that are not checked in these
+	// repositories only
+	ExcludeImages []string `json:"exclude_images,omitempty"`
+}
+
+// ProfileConfig is the part of a profile that is not a setting of FileConfig.
+// The other keys of a profile replace the settings of the same name, e.g.
+// its owners or holds.
+type ProfileConfig struct {
+	// Env overrides environment variables like GITHUB_ORGANIZATION,
+	// TEMPORARY_FOLDER or GITHUB_TOKEN for this configuration only, $VAR
+	// references are expanded so that tokens can be kept out of the file
+	Env map[string]string `json:"env"`
+}
+
+// applyProfile records the environment of the profile named name, without
+// touching the process environment, and replaces the settings it overrides
+func (f *FileConfig) applyProfile(name string) error {
+	raw, ok := f.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	var profile ProfileConfig
+	if err := json.Unmarshal(raw, &profile); err != nil {
+		return fmt.Errorf("error parsing profile %s: %w", name, err)
+	}
+	f.env = environ{}
+	for key, value := range profile.Env {
+		f.env[key] = os.ExpandEnv(value)
+	}
+	if err := json.Unmarshal(raw, f); err != nil {
+		return fmt.Errorf("error parsing profile %s: %w", name, err)
+	}
+	return nil
+}
+
+// RepoSelectors selects the repositories tagged with one of Topics or whose
+// name matches one of NamePatterns, e.g. ns8-*
+type RepoSelectors struct {
+	Topics       []string `json:"topics,omitempty"`
+	NamePatterns []string `json:"name_patterns,omitempty"`
+}
+
+// IsZero reports whether no selector is configured
+func (s RepoSelectors) IsZero() bool {
+	return len(s.Topics) == 0 && len(s.NamePatterns) == 0
+}
+
+// Selects reports whether the repository named name with topics is selected
+func (s RepoSelectors) Selects(name string, topics []string) bool {
+	for _, t := range s.Topics {
+		if slices.Contains(topics, t) {
+			return true
+		}
+	}
+	for _, pattern := range s.NamePatterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// QuarantineConfig is a known bad version of matching images, Version is
+// compared to both the tag and its parsed version
+type QuarantineConfig struct {
+	Image   string `json:"image"`
+	Version string `json:"version"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// SchemeConfig selects the version scheme of matching images: semver,
+// calver, numeric or loose
+type SchemeConfig struct {
+	Image  string `json:"image"`
+	Scheme string `json:"scheme"`
+}
+
+// EOLConfig maps the images matching Image to a product of endoflife.date,
+// e.g. postgresql
+type EOLConfig struct {
+	Image   string `json:"image"`
+	Product string `json:"product"`
+}
+
+// StreamConfig declares the supported streams of matching images as version
+// prefixes, e.g. 15 and 16, a pin within a stream is only upgraded within it
+type StreamConfig struct {
+	Image   string   `json:"image"`
+	Streams []string `json:"streams"`
+}
+
+// VariantConfig extracts the flavor of the tags of matching images with a
+// regular expression whose single capture group is the flavor
+type VariantConfig struct {
+	Image   string `json:"image"`
+	Pattern string `json:"pattern"`
+}
+
+// VerifyConfig selects how the cosign signatures of matching images are
+// verified, either against PublicKey or against the keyless signing identity
+type VerifyConfig struct {
+	// Image is a pattern like ghcr.io/nethserver/*
+	Image string `json:"image"`
+	// PublicKey is the path of a PEM encoded cosign public key
+	PublicKey string `json:"public_key,omitempty"`
+	// Identity is the expected certificate email or URI and Issuer the
+	// expected OIDC issuer, e.g. https://token.actions.githubusercontent.com
+	Identity string `json:"identity,omitempty"`
+	Issuer   string `json:"issuer,omitempty"`
+	// Roots is the path of the PEM encoded Fulcio roots the certificate of
+	// keyless signatures must chain to, required with Identity
+	Roots string `json:"roots,omitempty"`
+}
+
+// RegistryConfig describes a private or self-hosted OCI registry
+type RegistryConfig struct {
+	Host     string `json:"host"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// PasswordEnv names an environment variable holding the password, to
+	// keep secrets out of the file
+	PasswordEnv string `json:"password_env,omitempty"`
+	// Auth selects a credential helper instead of a static password: ecr,
+	// gcr or acr
+	Auth      string `json:"auth,omitempty"`
+	PlainHTTP bool   `json:"plain_http,omitempty"`
+	// PageSize is the number of tags asked per page and MaxTags stops the
+	// listing of large repositories, 0 leaves both to the registry
+	PageSize int `json:"page_size,omitempty"`
+	MaxTags  int `json:"max_tags,omitempty"`
+	// CABundle, ClientCert and ClientKey are paths of PEM files, see
+	// TLSConfig
+	CABundle   string `json:"ca_bundle,omitempty"`
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	// InsecureSkipVerify accepts any certificate, for self-hosted registries
+	// with self-signed ones prefer CABundle
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	// Timeout, IdleConnTimeout and the pool sizes tune the connections to
+	// Host, see TLSConfig
+	Timeout             string `json:"timeout,omitempty"`
+	MaxConnsPerHost     int    `json:"max_conns_per_host,omitempty"`
+	MaxIdleConnsPerHost int    `json:"max_idle_conns_per_host,omitempty"`
+	IdleConnTimeout     string `json:"idle_conn_timeout,omitempty"`
+}
+
+// Endpoint returns the TLS and connection settings of r, ok is false when r
+// overrides none
+func (r RegistryConfig) Endpoint() (e TLSConfig, ok bool) {
+	e = TLSConfig{
+		Host:                r.Host,
+		CABundle:            r.CABundle,
+		ClientCert:          r.ClientCert,
+		ClientKey:           r.ClientKey,
+		InsecureSkipVerify:  r.InsecureSkipVerify,
+		Timeout:             r.Timeout,
+		MaxConnsPerHost:     r.MaxConnsPerHost,
+		MaxIdleConnsPerHost: r.MaxIdleConnsPerHost,
+		IdleConnTimeout:     r.IdleConnTimeout,
+	}
+	return e, e != TLSConfig{Host: r.Host}
+}
+
+// Secret returns the configured password, resolving PasswordEnv with getenv,
+// e.g. Config.Getenv
+func (r RegistryConfig) Secret(getenv func(string) string) string {
+	if r.PasswordEnv != "" {
+		return getenv(r.PasswordEnv)
+	}
+	return r.Password
+}
+
+// LoadFile reads the JSON configuration in fileName, with the settings of
+// profile applied when not empty. A missing file yields an empty
+// configuration.
+func LoadFile(fileName, profile string) (*FileConfig, error) {
+	fileCfg := &FileConfig{}
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) && profile == "" {
+		return fileCfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", fileName, err)
+	}
+	if err := json.Unmarshal(data, fileCfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", fileName, err)
+	}
+	if profile != "" {
+		if err := fileCfg.applyProfile(profile); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", fileName, err)
+		}
+	}
+	for i, r := range fileCfg.Registries {
+		if r.Host == "" {
+			return nil, fmt.Errorf("config file %s: registry %d has no host", fileName, i)
+		}
+	}
+	for _, pattern := range fileCfg.RequireSigned {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("config file %s: invalid image pattern %q: %w", fileName, pattern, err)
+		}
+	}
+	for i, r := range fileCfg.Repositories {
+		if _, err := path.Match(r.Name, ""); err != nil || r.Name == "" {
+			return nil, fmt.Errorf("config file %s: repository entry %d has an invalid name pattern %q", fileName, i, r.Name)
+		}
+	}
+	for _, r := range append([]RepositoryConfig{{Name: "*", ExcludeImages: fileCfg.ExcludeImages}}, fileCfg.Repositories...) {
+		for _, pattern := range r.ExcludeImages {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("config file %s: invalid excluded image pattern %q: %w", fileName, pattern, err)
+			}
+		}
+	}
+	for _, pattern := range fileCfg.ScanPatterns {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" || strings.Contains(pattern, "/") {
+			return nil, fmt.Errorf("config file %s: invalid scan pattern %q, expected a file name or glob", fileName, pattern)
+		}
+	}
+	for _, pattern := range fileCfg.RepoSelectors.NamePatterns {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("config file %s: invalid repository name pattern %q", fileName, pattern)
+		}
+	}
+	for _, pattern := range fileCfg.IgnoreDirs {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" || strings.Contains(pattern, "/") {
+			return nil, fmt.Errorf("config file %s: invalid ignored directory %q, expected a directory name or glob", fileName, pattern)
+		}
+	}
+	for i, o := range fileCfg.Owners {
+		if o.Name() == "" {
+			return nil, fmt.Errorf("config file %s: owner %d has neither organization nor user", fileName, i)
+		}
+		for _, pattern := range append(slices.Clone(o.Patterns), o.Exclude...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("config file %s: owner %s has an invalid pattern %q", fileName, o.Name(), pattern)
+			}
+		}
+	}
+	for i, v := range fileCfg.Verify {
+		if _, err := path.Match(v.Image, ""); err != nil || v.Image == "" {
+			return nil, fmt.Errorf("config file %s: verify entry %d has an invalid image pattern %q", fileName, i, v.Image)
+		}
+	}
+	for i, t := range fileCfg.TLS {
+		if t.Host == "" {
+			return nil, fmt.Errorf("config file %s: tls entry %d has no host", fileName, i)
+		}
+		if err := validDurations(t); err != nil {
+			return nil, fmt.Errorf("config file %s: tls entry %d: %w", fileName, i, err)
+		}
+	}
+	for _, r := range fileCfg.Registries {
+		if e, ok := r.Endpoint(); ok {
+			if err := validDurations(e); err != nil {
+				return nil, fmt.Errorf("config file %s: registry %s: %w", fileName, r.Host, err)
+			}
+		}
+	}
+	for i, h := range fileCfg.Holds {
+		for _, pattern := range []string{h.Repository, h.Image} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("config file %s: hold %d has an invalid pattern %q", fileName, i, pattern)
+			}
+		}
+		if _, err := time.Parse(time.DateOnly, h.Until); h.Until != "" && err != nil {
+			return nil, fmt.Errorf("config file %s: hold %d has an invalid date %q, expected YYYY-MM-DD", fileName, i, h.Until)
+		}
+	}
+	for i, s := range fileCfg.Streams {
+		if _, err := path.Match(s.Image, ""); err != nil || s.Image == "" {
+			return nil, fmt.Errorf("config file %s: stream entry %d has an invalid image pattern %q", fileName, i, s.Image)
+		}
+	}
+	return fileCfg, nil
+}
+
+// CheckFile loads CONFIG_FILE with the NS8_UPDATER_PROFILE profile and
+// returns what is wrong with it
+func CheckFile() error {
+	_, err := LoadFile(configFile(), os.Getenv("NS8_UPDATER_PROFILE"))
+	return err
+}
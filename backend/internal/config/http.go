@@ -3,33 +3,58 @@ package config
 import (
 	"fmt"
 	"net/http"
-	"time"
 )
 
 type Transport struct {
-	Base    http.RoundTripper
-	Token   string
+	Base  http.RoundTripper
+	Token string
+	// Tokens, when set, takes precedence over Token
+	Tokens  TokenSource
 	Headers map[string]string
 }
 
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	reqBodyCopy := req.Clone(req.Context())
-	reqBodyCopy.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.Token))
+	token := t.Token
+	if t.Tokens != nil {
+		var err error
+		if token, err = t.Tokens.Token(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if token != "" {
+		reqBodyCopy.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
 	for key, value := range t.Headers {
 		reqBodyCopy.Header.Set(key, value)
 	}
 	return t.Base.RoundTrip(reqBodyCopy)
 }
 
-func NewHttpClient(token string) *http.Client {
+// NewHttpClient returns the client used for GitHub API calls, base enforces
+// the timeouts so that they can differ by host
+func NewHttpClient(base http.RoundTripper, tokens TokenSource, userAgent string) *http.Client {
 	return &http.Client{
-		Timeout: time.Second * 30,
 		Transport: &Transport{
-			Base:  http.DefaultTransport,
-			Token: token,
+			Base:   base,
+			Tokens: tokens,
 			Headers: map[string]string{
 				"Accept":               "application/vnd.github+json",
 				"X-GitHub-Api-Version": "2022-11-28",
+				"User-Agent":           userAgent,
+			},
+		},
+	}
+}
+
+// NewPlainHttpClient returns an unauthenticated client for registries and git
+// transports that only carries our User-Agent
+func NewPlainHttpClient(base http.RoundTripper, userAgent string) *http.Client {
+	return &http.Client{
+		Transport: &Transport{
+			Base: base,
+			Headers: map[string]string{
+				"User-Agent": userAgent,
 			},
 		},
 	}
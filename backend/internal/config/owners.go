@@ -0,0 +1,83 @@
+package config
+
+import (
+	"path"
+)
+
+// OwnerConfig is a GitHub organization or user whose repositories are
+// scanned, each with its own token and clone folder
+type OwnerConfig struct {
+	// Organization or User names the owner, Organization wins when both are
+	// set
+	Organization string `json:"organization,omitempty"`
+	User         string `json:"user,omitempty"`
+	// TokenEnv names the environment variable holding the owner's token,
+	// GITHUB_TOKEN is used when empty
+	TokenEnv string `json:"token_env,omitempty"`
+	// TemporaryFolder is where the owner's repositories are cloned, a
+	// sub-folder of TEMPORARY_FOLDER named after the owner by default
+	TemporaryFolder string `json:"temporary_folder,omitempty"`
+	// Patterns and Exclude select repositories by name, e.g. ns8-*; no
+	// pattern selects every repository found
+	Patterns []string `json:"patterns,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+}
+
+// Name returns the organization or user login
+func (o OwnerConfig) Name() string {
+	if o.Organization != "" {
+		return o.Organization
+	}
+	return o.User
+}
+
+// Matches reports whether the repository named name is selected
+func (o OwnerConfig) Matches(name string) bool {
+	for _, pattern := range o.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(o.Patterns) == 0 {
+		return true
+	}
+	for _, pattern := range o.Patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnerConfigs returns the configured owners, or the single owner set
+// through GITHUB_ORGANIZATION or GITHUB_USERNAME
+func (c *Config) OwnerConfigs() []OwnerConfig {
+	if len(c.Owners) > 0 {
+		return c.Owners
+	}
+	owner := OwnerConfig{User: c.UserName, TemporaryFolder: c.TemporaryFolder}
+	if c.Organization != nil {
+		owner.Organization = *c.Organization
+	}
+	return []OwnerConfig{owner}
+}
+
+// ForOwner returns a copy of c targeting owner with its own token and clone
+// folder
+func (c *Config) ForOwner(owner OwnerConfig) *Config {
+	oc := *c
+	org := owner.Organization
+	oc.Organization = &org
+	oc.UserName = owner.User
+	if owner.TokenEnv != "" {
+		oc.GithubAPIKey = c.Getenv(owner.TokenEnv)
+		oc.GitHubTokens = StaticToken(oc.GithubAPIKey)
+		oc.GitHubClient = NewHttpClient(c.Transport, oc.GitHubTokens, c.UserAgent)
+	}
+	oc.TemporaryFolder = owner.TemporaryFolder
+	if oc.TemporaryFolder == "" {
+		oc.TemporaryFolder = path.Join(c.TemporaryFolder, owner.Name())
+	}
+	_ = checkTempDirExists(oc.TemporaryFolder)
+	return &oc
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry is a RoundTripper that repeats requests failing with a network error,
+// 429 or 5xx up to Attempts times in total, waiting Delay doubled at each
+// attempt plus jitter in between, or what Retry-After asks for, in seconds or
+// as an HTTP date. Waits are
+// capped at MaxDelay, a Retry-After beyond it returns the failed response
+// right away. Only requests safe to repeat are retried: GET, HEAD and git
+// fetches.
+type Retry struct {
+	Base     http.RoundTripper
+	Attempts int
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+func NewRetry(base http.RoundTripper, attempts int, delay, maxDelay time.Duration) *Retry {
+	return &Retry{Base: base, Attempts: attempts, Delay: delay, MaxDelay: maxDelay}
+}
+
+func (r *Retry) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.Attempts <= 1 || !replayable(req) {
+		return r.Base.RoundTrip(req)
+	}
+	delay := r.Delay
+	for attempt := 1; ; attempt++ {
+		resp, err := r.Base.RoundTrip(req)
+		if attempt >= r.Attempts || req.Context().Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+		wait := delay + rand.N(delay/2+1)
+		if r.MaxDelay > 0 {
+			wait = min(wait, r.MaxDelay)
+		}
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = after
+				if r.MaxDelay > 0 && wait > r.MaxDelay {
+					return resp, nil
+				}
+			}
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryAfter parses the value of a Retry-After header, a number of seconds or
+// an HTTP date, into the wait from now. A date already past waits nothing.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// replayable reports whether req can be sent again without side effects
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		// fetching over smart HTTP posts the wanted refs, it changes nothing
+		return gitFetch(req) && (req.Body == nil || req.GetBody != nil)
+	default:
+		return false
+	}
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
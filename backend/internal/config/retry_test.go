@@ -0,0 +1,64 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"0", 0, true},
+		{"-5", 0, true},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Monday, 01-Jan-24 12:01:00 GMT", time.Minute, true},
+		{"Mon Jan  1 12:00:10 2024", 10 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:59:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		wait, ok := retryAfter(tt.value, now)
+		if wait != tt.wait || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.value, wait, ok, tt.wait, tt.ok)
+		}
+	}
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		after    time.Duration
+		attempts int
+	}{
+		{"date within MaxDelay is waited for", 0, 2},
+		{"date beyond MaxDelay fails right away", time.Hour, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			r := NewRetry(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				if attempts > 1 {
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+				}
+				h := http.Header{}
+				h.Set("Retry-After", time.Now().Add(tt.after).UTC().Format(http.TimeFormat))
+				return &http.Response{StatusCode: http.StatusTooManyRequests, Header: h, Body: http.NoBody}, nil
+			}), 3, time.Millisecond, time.Minute)
+			req, _ := http.NewRequest(http.MethodGet, "https://ghcr.io/v2/", nil)
+			if _, err := r.RoundTrip(req); err != nil {
+				t.Fatal(err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Connection bounds the requests to a host and tunes their connection pool,
+// zero values keep the defaults
+type Connection struct {
+	// Timeout bounds a request attempt, reading the body included, and
+	// CloneTimeout replaces it for git fetches over smart HTTP, their packs
+	// take much longer to stream than an API response
+	Timeout             time.Duration
+	CloneTimeout        time.Duration
+	MaxConnsPerHost     int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+}
+
+// override returns c with the non-zero settings of o
+func (c Connection) override(o Connection) Connection {
+	if o.Timeout > 0 {
+		c.Timeout = o.Timeout
+	}
+	if o.MaxConnsPerHost > 0 {
+		c.MaxConnsPerHost = o.MaxConnsPerHost
+	}
+	if o.MaxIdleConnsPerHost > 0 {
+		c.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	}
+	if o.IdleConnTimeout > 0 {
+		c.IdleConnTimeout = o.IdleConnTimeout
+	}
+	return c
+}
+
+// apply sets the pool settings of c on t and wraps it to enforce the timeout
+func (c Connection) apply(t *http.Transport) http.RoundTripper {
+	t.MaxConnsPerHost = c.MaxConnsPerHost
+	if c.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	if c.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.Timeout <= 0 && c.CloneTimeout <= 0 {
+		return t
+	}
+	return &timeoutTransport{base: t, timeout: c.Timeout, cloneTimeout: c.CloneTimeout}
+}
+
+// timeoutTransport cancels the requests still running after timeout, or
+// cloneTimeout for git fetches, unlike http.Client.Timeout it can differ from
+// one host to another
+type timeoutTransport struct {
+	base         http.RoundTripper
+	timeout      time.Duration
+	cloneTimeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timeout := t.timeout
+	if gitFetch(req) {
+		timeout = t.cloneTimeout
+	}
+	if timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the timeout of its request once closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// gitFetch reports whether req is part of a git clone or fetch over smart
+// HTTP, the ref advertisement or the pack negotiation
+func gitFetch(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/git-upload-pack") ||
+		strings.HasSuffix(req.URL.Path, "/info/refs") && req.URL.Query().Get("service") == "git-upload-pack"
+}
+
+// validDurations checks the durations of the connection settings of e
+func validDurations(e TLSConfig) error {
+	for _, d := range []string{e.Timeout, e.IdleConnTimeout} {
+		if _, err := time.ParseDuration(d); d != "" && err != nil {
+			return fmt.Errorf("invalid duration %q", d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// TLSConfig overrides the TLS and connection settings of the requests to
+// Host, e.g. an internal registry or GitHub Enterprise behind a private PKI
+type TLSConfig struct {
+	Host string `json:"host"`
+	// CABundle is the path of PEM certificates trusted for Host besides the
+	// system ones and CA_BUNDLE
+	CABundle string `json:"ca_bundle,omitempty"`
+	// ClientCert and ClientKey are the paths of the PEM client certificate
+	// and key presented to Host
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	// InsecureSkipVerify accepts any certificate, prefer CABundle
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	// Timeout and IdleConnTimeout are durations like 10s, they override
+	// HTTP_TIMEOUT and HTTP_IDLE_CONN_TIMEOUT for Host, as the pool sizes
+	// override HTTP_MAX_CONNS_PER_HOST and HTTP_MAX_IDLE_CONNS_PER_HOST
+	Timeout             string `json:"timeout,omitempty"`
+	MaxConnsPerHost     int    `json:"max_conns_per_host,omitempty"`
+	MaxIdleConnsPerHost int    `json:"max_idle_conns_per_host,omitempty"`
+	IdleConnTimeout     string `json:"idle_conn_timeout,omitempty"`
+}
+
+// Connection returns the connection settings of e, durations are validated
+// when the configuration file is loaded
+func (e TLSConfig) Connection() Connection {
+	timeout, _ := time.ParseDuration(e.Timeout)
+	idle, _ := time.ParseDuration(e.IdleConnTimeout)
+	return Connection{Timeout: timeout, MaxConnsPerHost: e.MaxConnsPerHost, MaxIdleConnsPerHost: e.MaxIdleConnsPerHost, IdleConnTimeout: idle}
+}
+
+// NewBaseTransport returns the transport under the breaker. It uses the
+// proxy from HTTPS_PROXY, HTTP_PROXY and NO_PROXY, trusts the certificates in
+// caFile besides the system ones, bounds the requests with conn and applies
+// the TLS and connection settings of endpoints to their hosts.
+func NewBaseTransport(caFile string, conn Connection, endpoints []TLSConfig) (http.RoundTripper, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	t.TLSClientConfig = &tls.Config{}
+	if caFile != "" {
+		pool, err := certPool(nil, caFile)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	if len(endpoints) == 0 {
+		return conn.apply(t), nil
+	}
+
+	hosts := &hostTransport{fallback: conn.apply(t.Clone()), hosts: map[string]http.RoundTripper{}}
+	for _, e := range endpoints {
+		et := t.Clone()
+		if e.CABundle != "" {
+			pool, err := certPool(t.TLSClientConfig.RootCAs, e.CABundle)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", e.Host, err)
+			}
+			et.TLSClientConfig.RootCAs = pool
+		}
+		if e.ClientCert != "" || e.ClientKey != "" {
+			cert, err := tls.LoadX509KeyPair(e.ClientCert, e.ClientKey)
+			if err != nil {
+				return nil, fmt.Errorf("%s: error loading client certificate: %w", e.Host, err)
+			}
+			et.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		}
+		et.TLSClientConfig.InsecureSkipVerify = e.InsecureSkipVerify
+		hosts.hosts[e.Host] = conn.override(e.Connection()).apply(et)
+	}
+	return hosts, nil
+}
+
+// certPool returns base, or the system pool when nil, extended with the
+// certificates in caFile
+func certPool(base *x509.CertPool, caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA bundle: %w", err)
+	}
+	pool := base
+	if pool == nil {
+		if pool, err = x509.SystemCertPool(); err != nil {
+			pool = x509.NewCertPool()
+		}
+	} else {
+		pool = pool.Clone()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in CA bundle %s", caFile)
+	}
+	return pool, nil
+}
+
+// hostTransport sends the requests through the transport configured for
+// their host, or fallback
+type hostTransport struct {
+	fallback http.RoundTripper
+	hosts    map[string]http.RoundTripper
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ht, ok := t.hosts[req.URL.Host]; ok {
+		return ht.RoundTrip(req)
+	}
+	return t.fallback.RoundTrip(req)
+}
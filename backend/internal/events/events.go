@@ -0,0 +1,87 @@
+// Package events carries what happens during a run to the interested parts
+// of the program, e.g. a progress display, without them reaching into the
+// updater.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/report"
+)
+
+// Type tells what happened
+type Type string
+
+const (
+	// RepositoryStarted is published before a repository is scanned
+	RepositoryStarted Type = "repository started"
+	// RepositoryScanned is published once the images of a repository are
+	// found, Images is their number
+	RepositoryScanned Type = "repository scanned"
+	// DependencyChecked is published for every checked dependency
+	DependencyChecked Type = "dependency checked"
+	// RepositoryDone is published once every image of a repository is
+	// checked
+	RepositoryDone Type = "repository done"
+	// Failed is published when a repository can't be scanned, Err tells why
+	Failed Type = "failed"
+)
+
+// Event is something that happened during a run
+type Event struct {
+	Type       Type
+	Time       time.Time
+	Repository string
+	// Images is the number of images found, set on RepositoryScanned
+	Images int
+	// Dependency is set on DependencyChecked
+	Dependency *report.Dependency
+	Err        error
+}
+
+// Bus delivers the published events to every subscriber, in the publishing
+// goroutine. The zero value is ready to use and a nil Bus drops events.
+type Bus struct {
+	mu   sync.Mutex
+	next int
+	subs map[int]func(Event)
+}
+
+// Subscribe calls fn with every event published from now on, until the
+// returned function is called
+func (b *Bus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = map[int]func(Event){}
+	}
+	id := b.next
+	b.next++
+	b.subs[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+// Publish delivers e to the subscribers, stamping it with the current time
+// when unset
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	subs := make([]func(Event), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.Unlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+}
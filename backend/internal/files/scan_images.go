@@ -1,29 +1,250 @@
 package files
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io/fs"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// ErrScanBudgetExceeded is returned along with the images found so far when
+// a scan runs out of time or files
+var ErrScanBudgetExceeded = errors.New("scan budget exceeded")
+
+// ErrUnreadableFiles is returned along with the images found in the other
+// files when some matching files could not be read
+var ErrUnreadableFiles = errors.New("unreadable files")
+
+// ScanOptions bounds the work spent on a single repository
+type ScanOptions struct {
+	// Timeout is the time budget of the scan, 0 means unlimited
+	Timeout time.Duration
+	// MaxFiles is the number of files visited before giving up, 0 means
+	// unlimited
+	MaxFiles int
+	// MaxFileSize skips matching files larger than this many bytes, 0 means
+	// unlimited
+	MaxFileSize int64
+	// IgnoreDirs are the names, or glob patterns, of the directories not
+	// descended into, e.g. node_modules
+	IgnoreDirs []string
+	// Logger receives debug messages about skipped files, nil discards them
+	Logger *log.Logger
+	// Diagnose, when set, receives a diagnostic for every file matching the
+	// scanned names
+	Diagnose func(Diagnostic)
+	// Scanner extracts the images, nil only knows the built-in registries
+	Scanner *Scanner
+}
+
+func (o ScanOptions) scanner() *Scanner {
+	if o.Scanner == nil {
+		return defaultScanner
+	}
+	return o.Scanner
+}
+
+// Diagnostic tells what scanning a single file yielded, to spot files whose
+// formatting the image patterns silently miss
+type Diagnostic struct {
+	Repository string `json:"repository,omitempty"`
+	File       string `json:"file"`
+	// Patterns are the expressions the content was matched against
+	Patterns []string `json:"patterns"`
+	Images   int      `json:"images"`
+	// Skipped tells why the file was not scanned, e.g. binary file
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Record reports the outcome of scanning file when a Diagnose hook is set
+func (o ScanOptions) Record(file string, images int, skipped string) {
+	if o.Diagnose == nil {
+		return
+	}
+	o.Diagnose(Diagnostic{
+		File:     file,
+		Patterns: []string{o.scanner().imageRegex.String()},
+		Images:   images,
+		Skipped:  skipped,
+	})
+}
+
+// binarySniffLen is how much of a file is inspected for NUL bytes, the same
+// heuristic git uses
+const binarySniffLen = 8000
+
+// DefaultIgnoreDirs are the directories skipped unless configured otherwise,
+// vendored code and fixtures reference images that are not ours to update
+var DefaultIgnoreDirs = []string{".git", "node_modules", "vendor", "testdata"}
+
+// InIgnoredDir reports whether the slash separated relative path p lies in a
+// directory matching one of ignoreDirs
+func InIgnoredDir(ignoreDirs []string, p string) bool {
+	dirs := strings.Split(p, "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		for _, pattern := range ignoreDirs {
+			if ok, _ := path.Match(pattern, dir); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TooLarge reports whether a file of size bytes must be skipped
+func (o ScanOptions) TooLarge(size int64) bool {
+	return o.MaxFileSize > 0 && size > o.MaxFileSize
+}
+
+// IsBinary reports whether data looks like a binary file
+func IsBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
+
+// Debugf logs a debug message when a logger is configured
+func (o ScanOptions) Debugf(format string, args ...any) {
+	if o.Logger != nil {
+		o.Logger.Printf(format, args...)
+	}
+}
+
+// Budget tracks the remaining scan budget
+type Budget struct {
+	deadline time.Time
+	maxFiles int
+	files    int
+}
+
+// NewBudget starts the clock for a scan bounded by opts
+func NewBudget(opts ScanOptions) *Budget {
+	b := &Budget{maxFiles: opts.MaxFiles}
+	if opts.Timeout > 0 {
+		b.deadline = time.Now().Add(opts.Timeout)
+	}
+	return b
+}
+
+// Spend accounts for one more file and reports an error wrapping
+// ErrScanBudgetExceeded once the budget is used up
+func (b *Budget) Spend() error {
+	b.files++
+	if b.maxFiles > 0 && b.files > b.maxFiles {
+		return fmt.Errorf("%w: more than %d files", ErrScanBudgetExceeded, b.maxFiles)
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return fmt.Errorf("%w: time limit reached after %d files", ErrScanBudgetExceeded, b.files-1)
+	}
+	return nil
+}
+
 type DockerImage struct {
 	Registry string
 	Repo     string
 	Tag      string
 	Raw      string
+	// Digest is set when the reference pins a digest, e.g. name:tag@sha256:...
+	Digest string
+	// File is the path of the file the image was found in, relative to the
+	// scanned directory.
+	File string
+	// Variables are the shell variables the reference was built from, e.g.
+	// penpot_version shared by the frontend, backend and exporter images
+	Variables []string
+	// Line and Column locate the first occurrence of the reference, both
+	// 1-based, Snippet is that line without surrounding spaces
+	Line    int
+	Column  int
+	Snippet string
+	// Key is the variable or key the reference is assigned to, e.g.
+	// postgres_image or image, empty when it is not the first value of an
+	// assignment on its line
+	Key string
+	// Occurrence counts the earlier references to the same image under the
+	// same Key in File, e.g. 1 for the second of two keyless references
+	Occurrence int
 }
 
-func FindDockerImages(dir string, fileNames map[string]bool) ([]DockerImage, error) {
-	imageSet := make(map[string]DockerImage)
+// DependencyKindDocker identifies container image references.
+const DependencyKindDocker = "docker"
+
+// DependencyID returns a deterministic identifier for a dependency. It
+// depends on where the dependency lives, what it is and the key it is
+// assigned to, never on its current version or line number, so rules keyed
+// by it keep matching when lines move, are reordered or are added around it.
+// Renaming the key changes the identifier. References to the same name under
+// the same key, or without any, are told apart by their occurrence, their
+// identifiers follow their order in file.
+func DependencyID(repository, file, kind, name, key string, occurrence int) string {
+	parts := []string{repository, file, kind, name}
+	if key != "" || occurrence > 0 {
+		// a single keyless reference keeps the identifier it always had
+		parts = append(parts, key, strconv.Itoa(occurrence))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Name returns the image name without its tag.
+func (img DockerImage) Name() string {
+	return img.Registry + "/" + img.Repo
+}
+
+// ID returns the stable dependency identifier of the image within repository.
+func (img DockerImage) ID(repository string) string {
+	return DependencyID(repository, img.File, DependencyKindDocker, img.Name(), img.Key, img.Occurrence)
+}
+
+var builtinRegistries = []string{"docker.io", "ghcr.io", "quay.io", "registry.k8s.io"}
 
-	registryPattern := `(docker\.io|ghcr\.io|quay\.io|registry\.k8s\.io)`
-	imageRegex := regexp.MustCompile(
-		registryPattern +
+// Scanner extracts the references to images of the built-in registries and
+// of the registries it was created with
+type Scanner struct {
+	imageRegex *regexp.Regexp
+}
+
+// NewScanner returns a scanner recognising images hosted on hosts in
+// addition to the built-in registries
+func NewScanner(hosts []string) *Scanner {
+	return &Scanner{imageRegex: buildImageRegex(hosts)}
+}
+
+var defaultScanner = NewScanner(nil)
+
+func buildImageRegex(extraHosts []string) *regexp.Regexp {
+	hosts := make([]string, 0, len(builtinRegistries)+len(extraHosts))
+	for _, h := range append(builtinRegistries, extraHosts...) {
+		hosts = append(hosts, regexp.QuoteMeta(h))
+	}
+	return regexp.MustCompile(
+		`(` + strings.Join(hosts, "|") + `)` +
 			`/[a-zA-Z0-9._/-]+` +
 			`(?::[^\s"]+)?`,
 	)
+}
+
+// FindDockerImages walks dir and extracts the docker images of every file
+// whose name matches fileNames, see MatchesName. When opts' budget runs out the images found so far are
+// returned together with an error wrapping ErrScanBudgetExceeded. The walk
+// stops with ctx's error when ctx is cancelled.
+func FindDockerImages(ctx context.Context, dir string, fileNames map[string]bool, opts ScanOptions) ([]DockerImage, error) {
+	imageSet := make(map[string]DockerImage)
+	budget := NewBudget(opts)
+	var budgetErr error
 
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -31,26 +252,52 @@ func FindDockerImages(dir string, fileNames map[string]bool) ([]DockerImage, err
 		}
 		fileName := filepath.Base(path)
 
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if d.IsDir() {
+			if path != dir && InIgnoredDir(opts.IgnoreDirs, fileName+"/") {
+				opts.Debugf("skipping ignored directory %s", path)
+				return filepath.SkipDir
+			}
 			return nil
 		}
+		if err := budget.Spend(); err != nil {
+			budgetErr = err
+			return fs.SkipAll
+		}
 
-		if _, exists := fileNames[fileName]; !exists {
+		if !MatchesName(fileNames, fileName) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if opts.TooLarge(info.Size()) {
+			opts.Debugf("skipping %s: %d bytes exceeds the %d bytes limit", path, info.Size(), opts.MaxFileSize)
+			opts.Record(relPath, 0, fmt.Sprintf("%d bytes exceeds the %d bytes limit", info.Size(), opts.MaxFileSize))
 			return nil
 		}
 		data, err := os.ReadFile(path)
 		if err != nil {
 			return err
 		}
+		if IsBinary(data) {
+			opts.Debugf("skipping %s: binary file", path)
+			opts.Record(relPath, 0, "binary file")
+			return nil
+		}
 
-		content := stripComments(string(data))
-		vars := extractBashVars(content)
-
-		matches := imageRegex.FindAllString(content, -1)
-		for _, raw := range matches {
-			resolved := resolveVars(raw, vars)
-			img := parseImage(resolved)
-			imageSet[img.Raw] = img
+		found := opts.scanner().ScanContent(relPath, data)
+		opts.Record(relPath, len(found), "")
+		for _, img := range found {
+			imageSet[img.File+"\x00"+img.Raw] = img
 		}
 
 		return nil
@@ -64,9 +311,51 @@ func FindDockerImages(dir string, fileNames map[string]bool) ([]DockerImage, err
 		images = append(images, img)
 	}
 
-	return images, nil
+	return images, budgetErr
 }
 
+// ScanContent extracts the docker images referenced in the content of a
+// single file, file is recorded as their location
+func (s *Scanner) ScanContent(file string, data []byte) []DockerImage {
+	content := stripComments(string(data))
+	vars := extractBashVars(content)
+
+	// comments are blanked up to the end of their line, offsets still match
+	// the original lines
+	lines := strings.Split(string(data), "\n")
+	seen := make(map[string]bool)
+	occurrences := make(map[string]int)
+	var images []DockerImage
+	for _, loc := range s.imageRegex.FindAllStringIndex(content, -1) {
+		raw := content[loc[0]:loc[1]]
+		resolved := resolveVars(raw, vars)
+		img := parseImage(resolved)
+		img.File = file
+		img.Variables = referencedVars(raw, vars)
+		if seen[img.Raw] {
+			continue
+		}
+		seen[img.Raw] = true
+		lineStart := strings.LastIndexByte(content[:loc[0]], '\n') + 1
+		if m := assignedKey.FindStringSubmatch(content[lineStart:loc[0]]); m != nil {
+			img.Key = m[1]
+		}
+		img.Occurrence = occurrences[img.Name()+"\x00"+img.Key]
+		occurrences[img.Name()+"\x00"+img.Key]++
+		img.Line = strings.Count(content[:loc[0]], "\n") + 1
+		img.Column = loc[0] - lineStart + 1
+		if img.Line <= len(lines) {
+			img.Snippet = strings.TrimSpace(lines[img.Line-1])
+		}
+		images = append(images, img)
+	}
+	return images
+}
+
+// assignedKey matches the end of a line assigning the reference that follows,
+// e.g. postgres_image=", image: or "db": "
+var assignedKey = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_.-]*)["']?\s*[:=]\s*[("']*\s*$`)
+
 func parseImage(raw string) DockerImage {
 	tag := "latest"
 
@@ -75,6 +364,11 @@ func parseImage(raw string) DockerImage {
 	registry := parts[0]
 	repoAndTag := parts[1]
 
+	digest := ""
+	if i := strings.Index(repoAndTag, "@"); i != -1 {
+		digest = repoAndTag[i+1:]
+		repoAndTag = repoAndTag[:i]
+	}
 	if strings.Contains(repoAndTag, ":") {
 		rt := strings.SplitN(repoAndTag, ":", 2)
 		repoAndTag = rt[0]
@@ -85,13 +379,25 @@ func parseImage(raw string) DockerImage {
 		Registry: registry,
 		Repo:     repoAndTag,
 		Tag:      tag,
+		Digest:   digest,
 		Raw:      raw,
 	}
 }
 
-func resolveVars(input string, vars map[string]string) string {
-	varRef := regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+var varRef = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// referencedVars returns the known variables input refers to
+func referencedVars(input string, vars map[string]string) []string {
+	var names []string
+	for _, m := range varRef.FindAllStringSubmatch(input, -1) {
+		if _, ok := vars[m[1]]; ok && !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
 
+func resolveVars(input string, vars map[string]string) string {
 	return varRef.ReplaceAllStringFunc(input, func(m string) string {
 		name := varRef.FindStringSubmatch(m)[1]
 		if v, ok := vars[name]; ok {
@@ -159,3 +465,17 @@ func stripComments(content string) string {
 	}
 	return strings.Join(result, "\n")
 }
+
+// MatchesName reports whether the base name of a file is one of fileNames,
+// whose keys may also be glob patterns like *.containerfile
+func MatchesName(fileNames map[string]bool, name string) bool {
+	if fileNames[name] {
+		return true
+	}
+	for pattern := range fileNames {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package files
+
+import (
+	"testing"
+)
+
+// ids returns the dependency identifiers of the images in content by tag
+func ids(t *testing.T, content string) map[string]string {
+	t.Helper()
+	ids := map[string]string{}
+	for _, img := range NewScanner(nil).ScanContent("build-images.sh", []byte(content)) {
+		ids[img.Tag] = img.ID("nethserver/ns8-mail")
+	}
+	return ids
+}
+
+func TestDependencyIDStability(t *testing.T) {
+	const base = `
+postgres_image="docker.io/library/postgres:15.4"
+legacy_image="docker.io/library/postgres:13.11"
+`
+	tests := []struct {
+		name    string
+		content string
+		// stable lists the tags whose identifier must match base, changed
+		// those whose identifier must not
+		stable  []string
+		changed []string
+	}{
+		{
+			name: "lines moved",
+			content: `
+# moved down
+
+postgres_image="docker.io/library/postgres:15.4"
+legacy_image="docker.io/library/postgres:13.11"
+`,
+			stable: []string{"15.4", "13.11"},
+		},
+		{
+			name: "references swapped",
+			content: `
+legacy_image="docker.io/library/postgres:13.11"
+postgres_image="docker.io/library/postgres:15.4"
+`,
+			stable: []string{"15.4", "13.11"},
+		},
+		{
+			name: "reference added above",
+			content: `
+next_image="docker.io/library/postgres:16.1"
+postgres_image="docker.io/library/postgres:15.4"
+legacy_image="docker.io/library/postgres:13.11"
+`,
+			stable: []string{"15.4", "13.11"},
+		},
+		{
+			name: "key renamed",
+			content: `
+postgres_image="docker.io/library/postgres:15.4"
+old_postgres_image="docker.io/library/postgres:13.11"
+`,
+			stable:  []string{"15.4"},
+			changed: []string{"13.11"},
+		},
+	}
+	want := ids(t, base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(t, tt.content)
+			for _, tag := range tt.stable {
+				if got[tag] != want[tag] {
+					t.Errorf("identifier of %s changed", tag)
+				}
+			}
+			for _, tag := range tt.changed {
+				if got[tag] == want[tag] {
+					t.Errorf("identifier of %s did not change", tag)
+				}
+			}
+		})
+	}
+
+	// a bump keeps the identifier of the reference it changes
+	if bumped := ids(t, `postgres_image="docker.io/library/postgres:15.6"`); bumped["15.6"] != want["15.4"] {
+		t.Error("bumping the version changed the identifier")
+	}
+}
+
+func TestDependencyIDKeyless(t *testing.T) {
+	// keyless references to the same image are told apart by their order,
+	// swapping them swaps their identifiers
+	label := ids(t, `--label="org.nethserver.images=docker.io/library/redis:7.2 docker.io/library/redis:6.2"`)
+	swapped := ids(t, `--label="org.nethserver.images=docker.io/library/redis:6.2 docker.io/library/redis:7.2"`)
+	if label["7.2"] == label["6.2"] {
+		t.Fatal("references share an identifier")
+	}
+	if label["7.2"] != swapped["6.2"] || label["6.2"] != swapped["7.2"] {
+		t.Error("identifiers of keyless references don't follow their order")
+	}
+
+	// a single keyless reference keeps the identifier without discriminator
+	single := NewScanner(nil).ScanContent("build-images.sh", []byte(`images="docker.io/library/redis:7.2 docker.io/library/alpine:3.20"`))
+	for _, img := range single {
+		if img.Key == "" && img.ID("r") != DependencyID("r", img.File, DependencyKindDocker, img.Name(), "", 0) {
+			t.Errorf("%s got a discriminator", img.Raw)
+		}
+	}
+}
+
+func TestAssignedKey(t *testing.T) {
+	tests := []struct {
+		content string
+		key     string
+	}{
+		{`postgres_image="docker.io/library/postgres:15.4"`, "postgres_image"},
+		{`postgres_image=docker.io/library/postgres:15.4`, "postgres_image"},
+		{`    image: docker.io/library/postgres:15.4`, "image"},
+		{`  "db": "docker.io/library/postgres:15.4",`, "db"},
+		{`images=("docker.io/library/postgres:15.4")`, "images"},
+		{`--label="org.nethserver.images=docker.io/library/postgres:15.4"`, "org.nethserver.images"},
+		{`podman pull docker.io/library/postgres:15.4`, ""},
+	}
+	for _, tt := range tests {
+		images := NewScanner(nil).ScanContent("build-images.sh", []byte(tt.content))
+		if len(images) != 1 || images[0].Key != tt.key {
+			t.Errorf("%s: got %+v, want key %q", tt.content, images, tt.key)
+		}
+	}
+}
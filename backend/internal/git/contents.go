@@ -0,0 +1,72 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/files"
+	"github.com/google/go-github/v81/github"
+)
+
+// FindFiles lists the files on the default branch of repo whose base name is
+// matches fileNames, see files.MatchesName, without cloning it
+func (c *GitHubClient) FindFiles(ctx context.Context, repo *github.Repository, fileNames map[string]bool) ([]*github.TreeEntry, error) {
+	tree, _, err := c.client.Git.GetTree(ctx, repo.GetOwner().GetLogin(), repo.GetName(), repo.GetDefaultBranch(), true)
+	if err != nil {
+		return nil, fmt.Errorf("error listing files of %s: %w", repo.GetFullName(), err)
+	}
+	if tree.GetTruncated() {
+		log.Printf("file list of %s is truncated, some files may not be scanned", repo.GetFullName())
+	}
+
+	var entries []*github.TreeEntry
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "blob" {
+			continue
+		}
+		if files.MatchesName(fileNames, path.Base(entry.GetPath())) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// ReadFile returns the content of filePath on the default branch of repo
+func (c *GitHubClient) ReadFile(ctx context.Context, repo *github.Repository, filePath string) ([]byte, error) {
+	file, _, _, err := c.client.Repositories.GetContents(
+		ctx,
+		repo.GetOwner().GetLogin(),
+		repo.GetName(),
+		filePath,
+		&github.RepositoryContentGetOptions{Ref: repo.GetDefaultBranch()},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s from %s: %w", filePath, repo.GetFullName(), err)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s in %s is not a file", filePath, repo.GetFullName())
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s from %s: %w", filePath, repo.GetFullName(), err)
+	}
+	return []byte(content), nil
+}
+
+// CommitsSince returns the commits on the default branch of repo touching
+// filePath after since, newest first
+func (c *GitHubClient) CommitsSince(ctx context.Context, repo *github.Repository, filePath string, since time.Time) ([]*github.RepositoryCommit, error) {
+	commits, _, err := c.client.Repositories.ListCommits(ctx, repo.GetOwner().GetLogin(), repo.GetName(), &github.CommitsListOptions{
+		SHA:         repo.GetDefaultBranch(),
+		Path:        filePath,
+		Since:       since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing commits of %s: %w", repo.GetFullName(), err)
+	}
+	return commits, nil
+}
@@ -2,16 +2,37 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/geniusdynamics/updater/backend/internal/config"
 	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/google/go-github/v81/github"
 )
 
+// ErrDirtyWorktree is returned when a clone has local changes, its content
+// would not match the remote repository
+var ErrDirtyWorktree = errors.New("worktree has local changes")
+
+// ErrSSORequired is returned when the organization enforces SAML single
+// sign-on and the token was not authorized for it
+var ErrSSORequired = errors.New("token not authorized for SAML single sign-on")
+
+// installProtocol routes the go-git HTTPS transport through our client once
+var installProtocol sync.Once
+
 type Repository struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
@@ -22,62 +43,304 @@ type GitHubClient struct {
 	UserName        string
 	Organization    *string
 	TemporaryFolder string
+	CloneDepth      int
+	// PageSize is the number of repositories asked per page, up to 100
+	PageSize int
+	// IncludeForks makes searches return forks, which they skip otherwise
+	IncludeForks bool
+	// TokenExpiration is the expiry of the token as reported by the last
+	// API response, zero for tokens without expiry
+	TokenExpiration time.Time
+	tokens          config.TokenSource
+	sshAuth         transport.AuthMethod
 }
 
 func NewGitHubClient(cfg *config.Config) *GitHubClient {
-	client := github.NewClient(cfg.GitHubClient)
-	return &GitHubClient{
-		client:          client,
+	// go-git transports are global, route clones through our client so
+	// they carry the configured User-Agent and CLONE_TIMEOUT as well, the
+	// first client installed serves every later one
+	if cfg.HttpClient != nil {
+		installProtocol.Do(func() {
+			client.InstallProtocol("https", githttp.NewClient(cfg.HttpClient))
+		})
+	}
+	c := &GitHubClient{
+		client:          github.NewClient(cfg.GitHubClient),
 		UserName:        cfg.UserName,
 		Organization:    cfg.Organization,
 		TemporaryFolder: cfg.TemporaryFolder,
+		CloneDepth:      cfg.CloneDepth,
+		PageSize:        cfg.GitHubPageSize,
+		IncludeForks:    cfg.IncludeForks,
+		tokens:          cfg.GitHubTokens,
+	}
+	if cfg.GitSSHKey != "" {
+		auth, err := gitssh.NewPublicKeysFromFile("git", cfg.GitSSHKey, "")
+		if err != nil {
+			log.Printf("error loading the SSH key, cloning over HTTPS: %s", err)
+		} else {
+			c.sshAuth = auth
+		}
 	}
+	return c
 }
 
-func (c *GitHubClient) GetRepositories() ([]*github.Repository, error) {
-	var repositories []*github.Repository
-	var err error
-	if c.Organization != nil && *c.Organization != "" {
-		repositories, _, err = c.client.Repositories.ListByOrg(context.Background(), *c.Organization, &github.RepositoryListByOrgOptions{})
-	} else {
-		repositories, _, err = c.client.Repositories.ListByUser(context.Background(), c.UserName, &github.RepositoryListByUserOptions{})
+// remote returns the URL to clone url from, its SSH form when an SSH key is
+// configured, and the credentials to use
+func (c *GitHubClient) remote(ctx context.Context, url string) (string, transport.AuthMethod, error) {
+	if c.sshAuth != nil {
+		if host, repoPath, ok := strings.Cut(strings.TrimPrefix(url, "https://"), "/"); ok {
+			url = "git@" + host + ":" + repoPath
+		}
 	}
-	if err != nil {
-		return nil, fmt.Errorf("an error occurred: %w", err)
+	auth, err := c.auth(ctx, url)
+	return url, auth, err
+}
+
+// auth returns the credentials for url, the SSH key for SSH remotes and the
+// token otherwise, so that private repositories can be cloned
+func (c *GitHubClient) auth(ctx context.Context, url string) (transport.AuthMethod, error) {
+	if strings.HasPrefix(url, "git@") || strings.HasPrefix(url, "ssh://") {
+		return c.sshAuth, nil
+	}
+	if c.tokens == nil {
+		return nil, nil
+	}
+	token, err := c.tokens.Token(ctx)
+	if err != nil || token == "" {
+		return nil, err
+	}
+	return &githttp.BasicAuth{Username: "x-access-token", Password: token}, nil
+}
+
+// originAuth returns the credentials for the origin remote of repo
+func (c *GitHubClient) originAuth(ctx context.Context, repo *git.Repository) (transport.AuthMethod, error) {
+	origin, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil || len(origin.Config().URLs) == 0 {
+		return nil, err
 	}
+	return c.auth(ctx, origin.Config().URLs[0])
+}
 
-	return repositories, nil
+// ssoError wraps err in ErrSSORequired when resp tells that the token must
+// be authorized for the organization's single sign-on
+func ssoError(resp *github.Response, err error) error {
+	if resp == nil || resp.Header.Get("X-GitHub-SSO") == "" {
+		return err
+	}
+	_, url, _ := strings.Cut(resp.Header.Get("X-GitHub-SSO"), "url=")
+	return fmt.Errorf("%w, authorize it at %s: %w", ErrSSORequired, url, err)
 }
 
-func (c *GitHubClient) SearchRepositories(search string) (*github.RepositoriesSearchResult, error) {
+// GetRepositories lists every repository of the organization or user, page
+// by page
+func (c *GitHubClient) GetRepositories(ctx context.Context) ([]*github.Repository, error) {
+	var repositories []*github.Repository
+	list := github.ListOptions{PerPage: c.PageSize}
+	for {
+		var page []*github.Repository
+		var resp *github.Response
+		err := c.rateLimited(ctx, func() (*github.Response, error) {
+			var err error
+			if c.Organization != nil && *c.Organization != "" {
+				page, resp, err = c.client.Repositories.ListByOrg(ctx, *c.Organization, &github.RepositoryListByOrgOptions{ListOptions: list})
+			} else {
+				page, resp, err = c.client.Repositories.ListByUser(ctx, c.UserName, &github.RepositoryListByUserOptions{ListOptions: list})
+			}
+			return resp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("an error occurred: %w", ssoError(resp, err))
+		}
+		c.TokenExpiration = resp.TokenExpiration.Time
+		repositories = append(repositories, page...)
+		if resp.NextPage == 0 {
+			return repositories, nil
+		}
+		list.Page = resp.NextPage
+	}
+}
+
+// SearchRepositories returns every repository of the organization or user
+// whose name matches search, the search API stops at 1000 results
+func (c *GitHubClient) SearchRepositories(ctx context.Context, search string) (*github.RepositoriesSearchResult, error) {
 	var searchQuery string
 	if c.Organization != nil && *c.Organization != "" {
 		searchQuery = "org:" + *c.Organization + " " + search + " in:name"
 	} else {
 		searchQuery = "user:" + c.UserName + " " + search + " in:name"
 	}
-	repositories, _, err := c.client.Search.Repositories(context.Background(), searchQuery, &github.SearchOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("error occurred when searching: %w", err)
+	if c.IncludeForks {
+		searchQuery += " fork:true"
 	}
-	for _, repo := range repositories.Repositories {
-		fmt.Printf("Name: %s, Search: %s \n", *repo.Name, searchQuery)
+	result := &github.RepositoriesSearchResult{}
+	opts := &github.SearchOptions{ListOptions: github.ListOptions{PerPage: c.PageSize}}
+	for {
+		var page *github.RepositoriesSearchResult
+		var resp *github.Response
+		err := c.rateLimited(ctx, func() (*github.Response, error) {
+			var err error
+			page, resp, err = c.client.Search.Repositories(ctx, searchQuery, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error occurred when searching: %w", ssoError(resp, err))
+		}
+		c.TokenExpiration = resp.TokenExpiration.Time
+		result.Total = page.Total
+		result.IncompleteResults = page.IncompleteResults
+		result.Repositories = append(result.Repositories, page.Repositories...)
+		if resp.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// maxRateLimitWaits bounds how many times a call is retried after hitting a
+// secondary rate limit
+const maxRateLimitWaits = 3
+
+// rateLimited runs call, waiting as long as GitHub asks when it hits a
+// secondary rate limit before running it again
+func (c *GitHubClient) rateLimited(ctx context.Context, call func() (*github.Response, error)) error {
+	for waits := 0; ; waits++ {
+		_, err := call()
+		var abuse *github.AbuseRateLimitError
+		if !errors.As(err, &abuse) || waits == maxRateLimitWaits {
+			return err
+		}
+		wait := time.Minute
+		if abuse.RetryAfter != nil {
+			wait = *abuse.RetryAfter
+		}
+		log.Printf("GitHub secondary rate limit hit, waiting %s", wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
 	}
-	return repositories, nil
 }
 
-func (c *GitHubClient) CloneRepository(url string) (string, error) {
+// ClonePath returns where the repository at url is cloned
+func (c *GitHubClient) ClonePath(url string) string {
 	lastUrl := strings.Split(url, "/")
-	target := filepath.Join(c.TemporaryFolder, lastUrl[len(lastUrl)-1])
-	_, err := git.PlainClone(target, false, &git.CloneOptions{
-		URL: url,
-	})
+	return filepath.Join(c.TemporaryFolder, lastUrl[len(lastUrl)-1])
+}
+
+func (c *GitHubClient) CloneRepository(ctx context.Context, url string) (string, error) {
+	target := c.ClonePath(url)
+	url, auth, err := c.remote(ctx, url)
 	if err != nil {
-		return "", fmt.Errorf("an error occurred while cloning repo: %s", err)
+		return "", err
+	}
+	opts := &git.CloneOptions{
+		URL:  url,
+		Auth: auth,
+	}
+	// scanning only needs the tip of the default branch
+	if c.CloneDepth > 0 {
+		opts.Depth = c.CloneDepth
+		opts.SingleBranch = true
+		opts.Tags = git.NoTags
+	}
+	_, err = git.PlainCloneContext(ctx, target, false, opts)
+	if err != nil {
+		// an interrupted clone would make the next one fail with "repository
+		// already exists"
+		_ = os.RemoveAll(target)
+		return "", fmt.Errorf("an error occurred while cloning repo: %w", err)
 	}
 	return target, nil
 }
 
+// PullRepository checks out branch, usually the default branch of the
+// repository, in the clone in dir and fast-forwards it to the remote one. It
+// refuses with ErrDirtyWorktree to touch a clone with local changes.
+func (c *GitHubClient) PullRepository(ctx context.Context, dir, branch string) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", dir, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("error opening the worktree of %s: %w", dir, err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return fmt.Errorf("error reading the status of %s: %w", dir, err)
+	}
+	if !status.IsClean() {
+		return fmt.Errorf("%w: %s", ErrDirtyWorktree, dir)
+	}
+	auth, err := c.originAuth(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("error reading the origin of %s: %w", dir, err)
+	}
+	if err := c.checkoutBranch(ctx, repo, worktree, branch, auth); err != nil {
+		return fmt.Errorf("error checking out %s in %s: %w", branch, dir, err)
+	}
+	opts := &git.PullOptions{ReferenceName: plumbing.NewBranchReferenceName(branch), Auth: auth}
+	if c.CloneDepth > 0 {
+		opts.Depth = c.CloneDepth
+		opts.SingleBranch = true
+	}
+	err = worktree.PullContext(ctx, opts)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("error pulling %s: %w", dir, err)
+	}
+	return nil
+}
+
+// checkoutBranch switches worktree to branch when something else is checked
+// out, fetching the remote branch first
+func (c *GitHubClient) checkoutBranch(ctx context.Context, repo *git.Repository, worktree *git.Worktree, branch string, auth transport.AuthMethod) error {
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	local := plumbing.NewBranchReferenceName(branch)
+	if head.Name() == local {
+		return nil
+	}
+	remote := plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch)
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{gitconfig.RefSpec("+" + local + ":" + remote)},
+		Depth:    c.CloneDepth,
+		Auth:     auth,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+	opts := &git.CheckoutOptions{Branch: local}
+	if _, err := repo.Reference(local, false); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		ref, err := repo.Reference(remote, true)
+		if err != nil {
+			return err
+		}
+		opts.Hash = ref.Hash()
+		opts.Create = true
+	}
+	return worktree.Checkout(opts)
+}
+
+// LocalRepositories returns the paths of the clones in the temporary folder
+func (c *GitHubClient) LocalRepositories() ([]string, error) {
+	entries, err := os.ReadDir(c.TemporaryFolder)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %w", c.TemporaryFolder, err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		dir := filepath.Join(c.TemporaryFolder, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, ".git")); entry.IsDir() && err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
 func (c *GitHubClient) RemoveClonedRepositories() error {
 	if err := os.RemoveAll(c.TemporaryFolder); err != nil {
 		return fmt.Errorf("failed to delete directory: %s", err)
@@ -87,3 +350,54 @@ func (c *GitHubClient) RemoveClonedRepositories() error {
 	}
 	return nil
 }
+
+// TokenStatus describes the token of the client as seen by GitHub
+type TokenStatus struct {
+	// Scopes are the OAuth scopes of classic tokens, nil for fine-grained
+	// and installation tokens which don't report them
+	Scopes []string
+	// Expiration is zero for tokens without expiry
+	Expiration time.Time
+}
+
+// CheckToken reads the organization or user of the client, to tell whether
+// the token grants access to it and which scopes it has
+func (c *GitHubClient) CheckToken(ctx context.Context) (TokenStatus, error) {
+	var resp *github.Response
+	var err error
+	if c.Organization != nil && *c.Organization != "" {
+		_, resp, err = c.client.Organizations.Get(ctx, *c.Organization)
+	} else {
+		_, resp, err = c.client.Users.Get(ctx, c.UserName)
+	}
+	if err != nil {
+		return TokenStatus{}, ssoError(resp, err)
+	}
+	status := TokenStatus{Expiration: resp.TokenExpiration.Time}
+	if header, ok := resp.Header["X-Oauth-Scopes"]; ok && len(header) > 0 {
+		status.Scopes = []string{}
+		for _, scope := range strings.Split(header[0], ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				status.Scopes = append(status.Scopes, scope)
+			}
+		}
+	}
+	return status, nil
+}
+
+// IsClean reports whether the clone in dir has no local changes
+func IsClean(dir string) (bool, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return false, fmt.Errorf("error opening %s: %w", dir, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return false, fmt.Errorf("error opening the worktree of %s: %w", dir, err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, fmt.Errorf("error reading the status of %s: %w", dir, err)
+	}
+	return status.IsClean(), nil
+}
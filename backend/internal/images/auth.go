@@ -0,0 +1,107 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator provides the credentials used to answer registry challenges
+type Authenticator interface {
+	Credentials(ctx context.Context) (username, password string, err error)
+}
+
+// BasicAuth is a static username and password
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Credentials(ctx context.Context) (string, string, error) {
+	return a.Username, a.Password, nil
+}
+
+// helperTTL is how long a password issued by a cloud CLI is reused, shorter
+// than the lifetime of ECR, GCR and ACR tokens
+const helperTTL = 10 * time.Minute
+
+// commandAuth obtains a short lived password from a cloud provider CLI
+type commandAuth struct {
+	username string
+	command  []string
+
+	mu       sync.Mutex
+	password string
+	expires  time.Time
+}
+
+func (a *commandAuth) Credentials(ctx context.Context) (string, string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if time.Now().Before(a.expires) {
+		return a.username, a.password, nil
+	}
+	out, err := exec.CommandContext(ctx, a.command[0], a.command[1:]...).Output()
+	if err != nil {
+		return "", "", fmt.Errorf("credential helper %q failed: %w", strings.Join(a.command, " "), err)
+	}
+	a.password = strings.TrimSpace(string(out))
+	a.expires = time.Now().Add(helperTTL)
+	return a.username, a.password, nil
+}
+
+// ECRAuth authenticates against Amazon ECR through the aws CLI, the region is
+// taken from hosts like 123456789012.dkr.ecr.eu-west-1.amazonaws.com
+func ECRAuth(host string) (Authenticator, error) {
+	parts := strings.Split(host, ".")
+	if len(parts) < 6 || parts[1] != "dkr" || parts[2] != "ecr" {
+		return nil, fmt.Errorf("%s is not an ECR registry host", host)
+	}
+	return &commandAuth{
+		username: "AWS",
+		command:  []string{"aws", "ecr", "get-login-password", "--region", parts[3]},
+	}, nil
+}
+
+// GCRAuth authenticates against GCR and Artifact Registry through gcloud
+func GCRAuth() Authenticator {
+	return &commandAuth{
+		username: "oauth2accesstoken",
+		command:  []string{"gcloud", "auth", "print-access-token"},
+	}
+}
+
+// ACRAuth authenticates against Azure Container Registry through the az CLI,
+// the registry name is taken from hosts like myregistry.azurecr.io
+func ACRAuth(host string) Authenticator {
+	name, _, _ := strings.Cut(host, ".")
+	return &commandAuth{
+		username: "00000000-0000-0000-0000-000000000000",
+		command:  []string{"az", "acr", "login", "--name", name, "--expose-token", "--output", "tsv", "--query", "accessToken"},
+	}
+}
+
+// NewAuthenticator builds the authenticator for a configured registry. kind
+// selects a credential helper, when empty the static username and password
+// are used, if any.
+func NewAuthenticator(kind, host, username, password string) (Authenticator, error) {
+	switch kind {
+	case "":
+		if username == "" && password == "" {
+			return nil, nil
+		}
+		return BasicAuth{Username: username, Password: password}, nil
+	case "ecr":
+		return ECRAuth(host)
+	case "gcr":
+		return GCRAuth(), nil
+	case "acr":
+		return ACRAuth(host), nil
+	default:
+		return nil, fmt.Errorf("registry %s: unknown auth helper %q", host, kind)
+	}
+}
@@ -0,0 +1,120 @@
+package images
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+// Client looks up tags, manifests, signatures and end of life dates with its
+// own HTTP client, registries and rules, so that several clients with their
+// own configuration may coexist in one program
+type Client struct {
+	httpClient *http.Client
+	dockerHub  DockerHubOptions
+
+	// mu guards registries, which RegisterRegistry may extend while lookups
+	// are in flight
+	mu         sync.RWMutex
+	registries map[string]Registry
+
+	verifyPolicies []verifyPolicy
+	variantRules   []variantRule
+	schemeRules    []schemeRule
+	streams        []config.StreamConfig
+	eolProducts    []config.EOLConfig
+	quarantine     []config.QuarantineConfig
+
+	eolMu     sync.Mutex
+	eolCycles map[string][]eolCycle
+}
+
+// NewClient returns a client with the HTTP client, Docker Hub listing,
+// registry, signature verification, tag variant, version scheme, stream, end
+// of life and quarantine settings of cfg
+func NewClient(ctx context.Context, cfg *config.Config) (*Client, error) {
+	c := &Client{
+		httpClient:  cfg.HttpClient,
+		registries:  map[string]Registry{},
+		streams:     slices.Clone(cfg.Streams),
+		eolProducts: append(slices.Clone(defaultEOLProducts), cfg.EOL...),
+		quarantine:  slices.Clone(cfg.Quarantine),
+		eolCycles:   map[string][]eolCycle{},
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	c.SetDockerHubOptions(DockerHubOptions{
+		MaxPages:    cfg.DockerHubMaxPages,
+		MaxTags:     cfg.DockerHubMaxTags,
+		Concurrency: cfg.DockerHubConcurrency,
+		PageSize:    cfg.DockerHubPageSize,
+		Ordering:    cfg.DockerHubOrdering,
+	})
+	for _, r := range defaultRegistries {
+		c.RegisterRegistry(r)
+	}
+	for _, r := range cfg.Registries {
+		auth, err := NewAuthenticator(r.Auth, r.Host, r.Username, r.Secret(cfg.Getenv))
+		if err != nil {
+			return nil, err
+		}
+		c.RegisterRegistry(Registry{Host: r.Host, PlainHTTP: r.PlainHTTP, Auth: auth, PageSize: r.PageSize, MaxTags: r.MaxTags})
+	}
+	for _, vc := range cfg.Verify {
+		v, err := NewVerifier(vc)
+		if err != nil {
+			return nil, err
+		}
+		c.verifyPolicies = append(c.verifyPolicies, verifyPolicy{pattern: vc.Image, verifier: v})
+	}
+	for _, vc := range cfg.Variants {
+		if err := c.addVariantRule(vc); err != nil {
+			return nil, err
+		}
+	}
+	for _, sc := range cfg.Schemes {
+		if err := c.addSchemeRule(sc); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.QuarantineURL != "" {
+		shared, err := c.loadQuarantine(ctx, cfg.QuarantineURL)
+		if err != nil {
+			return nil, err
+		}
+		c.quarantine = append(c.quarantine, shared...)
+	}
+	return c, nil
+}
+
+// RegisterRegistry adds or replaces a registry
+func (c *Client) RegisterRegistry(r Registry) {
+	r.client = c.httpClient
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registries[r.Host] = r
+}
+
+func (c *Client) lookupRegistry(host string) (Registry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok := c.registries[host]
+	return r, ok
+}
+
+// Hosts returns the hosts of the known registries, Docker Hub included
+func (c *Client) Hosts() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	hosts := []string{"docker.io"}
+	for host := range c.registries {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts[1:])
+	return hosts
+}
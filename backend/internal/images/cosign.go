@@ -0,0 +1,277 @@
+package images
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+// ErrNotSigned is returned when a tag has no cosign signature at all
+var ErrNotSigned = errors.New("no cosign signature")
+
+const (
+	cosignSignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+)
+
+// Fulcio certificate extensions holding the OIDC issuer, the first one is
+// deprecated but still set by older cosign releases
+var (
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Verifier checks cosign signatures against a public key or, for keyless
+// signing, against the identity in the signing certificate
+type Verifier struct {
+	// PublicKey verifies key based signatures, when nil the certificate
+	// attached to the signature is used
+	PublicKey crypto.PublicKey
+	// Identity is the expected certificate email or URI, e.g. a GitHub
+	// workflow, and Issuer the expected OIDC issuer
+	Identity string
+	Issuer   string
+	// Roots are the Fulcio certificates the signing certificate must chain
+	// to, keyless verification refuses to run without them. Rekor inclusion
+	// and SCTs are not checked, so a certificate issued by Roots is trusted
+	// whatever its transparency log entry.
+	Roots *x509.CertPool
+}
+
+type verifyPolicy struct {
+	pattern  string
+	verifier *Verifier
+}
+
+// NewVerifier builds the verifier of a configured image pattern
+func NewVerifier(c config.VerifyConfig) (*Verifier, error) {
+	v := &Verifier{Identity: c.Identity, Issuer: c.Issuer}
+	if c.PublicKey != "" {
+		data, err := os.ReadFile(c.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("error reading public key: %w", err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s is not a PEM file", c.PublicKey)
+		}
+		v.PublicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing public key %s: %w", c.PublicKey, err)
+		}
+	}
+	if c.Roots != "" {
+		data, err := os.ReadFile(c.Roots)
+		if err != nil {
+			return nil, fmt.Errorf("error reading certificate roots: %w", err)
+		}
+		v.Roots = x509.NewCertPool()
+		if !v.Roots.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", c.Roots)
+		}
+	}
+	if v.PublicKey == nil && v.Identity == "" {
+		return nil, fmt.Errorf("verification of %s needs a public key or an identity", c.Image)
+	}
+	if v.PublicKey == nil && v.Roots == nil {
+		return nil, fmt.Errorf("keyless verification of %s needs the Fulcio roots", c.Image)
+	}
+	return v, nil
+}
+
+// RequiresVerification reports whether proposed tags of image must carry a
+// valid signature
+func (c *Client) RequiresVerification(image string) bool {
+	return c.verifierFor(image) != nil
+}
+
+// verifierFor returns the first configured verifier matching image
+func (c *Client) verifierFor(image string) *Verifier {
+	for _, p := range c.verifyPolicies {
+		if ok, _ := path.Match(p.pattern, image); ok {
+			return p.verifier
+		}
+	}
+	return nil
+}
+
+// VerifyTag checks the cosign signature of tag with the verifier configured
+// for the image and describes what was verified
+func (c *Client) VerifyTag(ctx context.Context, registry, repo, tag string) (string, error) {
+	v := c.verifierFor(registry + "/" + repo)
+	if v == nil {
+		return "", fmt.Errorf("no verifier configured for %s/%s", registry, repo)
+	}
+	reg, repo, err := c.manifestRegistry(registry, repo)
+	if err != nil {
+		return "", err
+	}
+	return v.Verify(ctx, reg, repo, tag)
+}
+
+// Verify checks that one of the cosign signatures of tag in repo on reg is
+// valid and covers the manifest the tag points to
+func (v *Verifier) Verify(ctx context.Context, reg Registry, repo, tag string) (string, error) {
+	digest, err := reg.digest(ctx, repo, tag)
+	if err != nil {
+		return "", err
+	}
+	algo, sum, ok := strings.Cut(digest, ":")
+	if !ok {
+		return "", fmt.Errorf("%s: malformed digest %q", reg.Host, digest)
+	}
+
+	var sigManifest struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	err = reg.getManifest(ctx, repo, algo+"-"+sum+".sig", &sigManifest)
+	if errors.Is(err, ErrManifestNotFound) {
+		return "", fmt.Errorf("%s:%s: %w", repo, tag, ErrNotSigned)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = ErrNotSigned
+	for _, layer := range sigManifest.Layers {
+		sig := layer.Annotations[cosignSignatureAnnotation]
+		if sig == "" {
+			continue
+		}
+		payload, perr := reg.blob(ctx, repo, layer.Digest)
+		if perr != nil {
+			return "", perr
+		}
+		var result string
+		result, err = v.verifyPayload(payload, sig, layer.Annotations[cosignCertificateAnnotation], digest)
+		if err == nil {
+			return result, nil
+		}
+	}
+	return "", fmt.Errorf("%s:%s: %w", repo, tag, err)
+}
+
+// verifyPayload checks a simple signing payload and its signature
+func (v *Verifier) verifyPayload(payload []byte, sig, certPEM, digest string) (string, error) {
+	var simpleSigning struct {
+		Critical struct {
+			Image struct {
+				Digest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+		return "", fmt.Errorf("invalid signature payload: %w", err)
+	}
+	if simpleSigning.Critical.Image.Digest != digest {
+		return "", fmt.Errorf("signature covers %s instead of %s", simpleSigning.Critical.Image.Digest, digest)
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	pub := v.PublicKey
+	result := "cosign key"
+	if pub == nil {
+		cert, err := v.verifyCertificate(certPEM)
+		if err != nil {
+			return "", err
+		}
+		pub = cert.PublicKey
+		result = fmt.Sprintf("cosign keyless %s (%s)", v.Identity, certIssuer(cert))
+	}
+
+	hash := sha256.Sum256(payload)
+	if err := verifySignature(pub, hash[:], rawSig); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// verifyCertificate checks the keyless signing certificate against the
+// roots, then the expected identity and issuer, which are only trustworthy
+// once the chain is. Without a transparency log there is no trusted signing
+// time, so the chain is checked at the certificate's issue time.
+func (v *Verifier) verifyCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, errors.New("signature has no signing certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate: %w", err)
+	}
+
+	if v.Roots == nil {
+		return nil, errors.New("no Fulcio roots to check the signing certificate against")
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:       v.Roots,
+		CurrentTime: cert.NotBefore,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("untrusted signing certificate: %w", err)
+	}
+
+	identities := slices.Clone(cert.EmailAddresses)
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+	if !slices.Contains(identities, v.Identity) {
+		return nil, fmt.Errorf("signed by %s, expected %s", strings.Join(identities, ", "), v.Identity)
+	}
+	if issuer := certIssuer(cert); v.Issuer != "" && issuer != v.Issuer {
+		return nil, fmt.Errorf("identity issued by %s, expected %s", issuer, v.Issuer)
+	}
+	return cert, nil
+}
+
+// certIssuer returns the OIDC issuer recorded by Fulcio in cert
+func certIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidIssuerV1):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+func verifySignature(pub crypto.PublicKey, hash, sig []byte) error {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash, sig) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash, sig)
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
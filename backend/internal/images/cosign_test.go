@@ -0,0 +1,197 @@
+package images
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+const (
+	testDigest   = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	testIdentity = "https://github.com/nethserver/ns8-mail/.github/workflows/publish.yml@refs/heads/main"
+	testIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newCert issues a certificate for key signed by parent and parentKey, self
+// signed when parent is nil. Leaves carry identity and the Fulcio issuer
+// extension.
+func newCert(t *testing.T, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, identity string) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "sigstore"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(10 * time.Minute),
+	}
+	if identity == "" {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		uri, err := url.Parse(identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issuer, _ := asn1.Marshal(testIssuer)
+		tmpl.URIs = []*url.URL{uri}
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: oidIssuerV2, Value: issuer}}
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func certPEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+// sign returns a simple signing payload for digest and its signature by key
+func sign(t *testing.T, key *ecdsa.PrivateKey, digest string) ([]byte, string) {
+	t.Helper()
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"ghcr.io/nethserver/mail"},"image":{"docker-manifest-digest":"` + digest + `"},"type":"cosign container image signature"},"optional":null}`)
+	hash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload, base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerifyPayload(t *testing.T) {
+	rootKey, signingKey, otherKey := newKey(t), newKey(t), newKey(t)
+	root := newCert(t, rootKey, nil, nil, "")
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	leaf := newCert(t, signingKey, root, rootKey, testIdentity)
+	// a forged certificate claims the expected identity and issuer but
+	// doesn't chain to the roots
+	forged := newCert(t, signingKey, nil, nil, testIdentity)
+	payload, sig := sign(t, signingKey, testDigest)
+
+	keyless := &Verifier{Identity: testIdentity, Issuer: testIssuer, Roots: roots}
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		payload  []byte
+		cert     string
+		digest   string
+		want     string
+		err      string
+	}{
+		{name: "key", verifier: &Verifier{PublicKey: signingKey.Public()}, want: "cosign key"},
+		{name: "wrong key", verifier: &Verifier{PublicKey: otherKey.Public()}, err: "invalid ECDSA signature"},
+		{name: "other digest", verifier: &Verifier{PublicKey: signingKey.Public()}, digest: "sha256:4444", err: "signature covers"},
+		{name: "tampered payload", verifier: &Verifier{PublicKey: signingKey.Public()}, payload: []byte(strings.Replace(string(payload), "mail", "evil", 1)), err: "invalid ECDSA signature"},
+		{name: "keyless", verifier: keyless, cert: certPEM(leaf), want: "cosign keyless " + testIdentity + " (" + testIssuer + ")"},
+		{name: "keyless without certificate", verifier: keyless, err: "no signing certificate"},
+		{name: "forged self-signed certificate", verifier: keyless, cert: certPEM(forged), err: "untrusted signing certificate"},
+		{name: "keyless without roots", verifier: &Verifier{Identity: testIdentity}, cert: certPEM(forged), err: "no Fulcio roots"},
+		{name: "other identity", verifier: &Verifier{Identity: "https://github.com/evil/repo", Roots: roots}, cert: certPEM(leaf), err: "expected https://github.com/evil/repo"},
+		{name: "other issuer", verifier: &Verifier{Identity: testIdentity, Issuer: "https://accounts.google.com", Roots: roots}, cert: certPEM(leaf), err: "identity issued by"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, digest := payload, testDigest
+			if tt.payload != nil {
+				p = tt.payload
+			}
+			if tt.digest != "" {
+				digest = tt.digest
+			}
+			got, err := tt.verifier.verifyPayload(p, sig, tt.cert, digest)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %q, %v, want error containing %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	dir := t.TempDir()
+	key := newKey(t)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "cosign.pub")
+	rootsFile := filepath.Join(dir, "fulcio.pem")
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	writeFile(t, rootsFile, []byte(certPEM(newCert(t, key, nil, nil, ""))))
+
+	tests := []struct {
+		name string
+		cfg  config.VerifyConfig
+		err  string
+	}{
+		{name: "key", cfg: config.VerifyConfig{PublicKey: keyFile}},
+		{name: "keyless", cfg: config.VerifyConfig{Identity: testIdentity, Roots: rootsFile}},
+		{name: "keyless without roots", cfg: config.VerifyConfig{Identity: testIdentity}, err: "needs the Fulcio roots"},
+		{name: "nothing", cfg: config.VerifyConfig{}, err: "needs a public key or an identity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Image = "ghcr.io/nethserver/*"
+			v, err := NewVerifier(tt.cfg)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.cfg.PublicKey != "" && !v.PublicKey.(*ecdsa.PublicKey).Equal(key.Public()) {
+				t.Error("public key not loaded")
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
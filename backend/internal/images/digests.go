@@ -0,0 +1,84 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// annotationCreated is the OCI annotation holding the image build date
+const annotationCreated = "org.opencontainers.image.created"
+
+// TagDetails is what a tag currently points to
+type TagDetails struct {
+	Digest string
+	// Created is the build date from the manifest annotations or the image
+	// configuration, zero when the registry doesn't expose it
+	Created time.Time
+}
+
+type manifestDocument struct {
+	Annotations map[string]string `json:"annotations"`
+	Config      struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Manifests []struct {
+		Digest string `json:"digest"`
+	} `json:"manifests"`
+}
+
+// ResolveTag returns the digest and build date of tag, for tags like latest
+// whose name says nothing about their content
+func (c *Client) ResolveTag(ctx context.Context, registry, repo, tag string) (TagDetails, error) {
+	reg, repo, err := c.manifestRegistry(registry, repo)
+	if err != nil {
+		return TagDetails{}, err
+	}
+	digest, err := reg.digest(ctx, repo, tag)
+	if err != nil {
+		return TagDetails{}, err
+	}
+	created, err := reg.created(ctx, repo, digest)
+	if err != nil {
+		return TagDetails{}, err
+	}
+	return TagDetails{Digest: digest, Created: created}, nil
+}
+
+// created looks up the build date of a manifest, following the first entry of
+// multi-platform indexes
+func (r Registry) created(ctx context.Context, repo, digest string) (time.Time, error) {
+	var m manifestDocument
+	if err := r.getManifest(ctx, repo, digest, &m); err != nil {
+		return time.Time{}, err
+	}
+	if ts := m.Annotations[annotationCreated]; ts != "" {
+		return time.Parse(time.RFC3339, ts)
+	}
+	if len(m.Manifests) > 0 {
+		var platform manifestDocument
+		if err := r.getManifest(ctx, repo, m.Manifests[0].Digest, &platform); err != nil {
+			return time.Time{}, err
+		}
+		if ts := platform.Annotations[annotationCreated]; ts != "" {
+			return time.Parse(time.RFC3339, ts)
+		}
+		m = platform
+	}
+	if m.Config.Digest == "" {
+		return time.Time{}, nil
+	}
+
+	data, err := r.blob(ctx, repo, m.Config.Digest)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var imageConfig struct {
+		Created time.Time `json:"created"`
+	}
+	if err := json.Unmarshal(data, &imageConfig); err != nil {
+		return time.Time{}, fmt.Errorf("%s: invalid image configuration %s: %w", r.Host, m.Config.Digest, err)
+	}
+	return imageConfig.Created, nil
+}
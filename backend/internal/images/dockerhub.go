@@ -0,0 +1,178 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// dockerHubMaxPageSize is the largest page Docker Hub serves
+const dockerHubMaxPageSize = 100
+
+// ErrTagsTruncated is returned along with the tags listed so far when the
+// listing stopped at MaxPages before the last page
+var ErrTagsTruncated = errors.New("tag listing truncated")
+
+// DockerHubOptions bounds the tag listing of Docker Hub images, popular
+// images like postgres have well over a hundred pages of tags
+type DockerHubOptions struct {
+	// MaxPages and MaxTags stop the listing, 0 means unlimited
+	MaxPages int
+	MaxTags  int
+	// Concurrency is the number of pages fetched at once
+	Concurrency int
+	// PageSize is the number of tags per page, up to 100
+	PageSize int
+	// Ordering sorts the tags, e.g. last_updated or name, empty keeps the
+	// API default
+	Ordering string
+}
+
+// SetDockerHubOptions replaces the Docker Hub listing bounds
+func (c *Client) SetDockerHubOptions(opts DockerHubOptions) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.PageSize < 1 || opts.PageSize > dockerHubMaxPageSize {
+		opts.PageSize = dockerHubMaxPageSize
+	}
+	c.dockerHub = opts
+}
+
+// getDockerHubTags handles Docker Hub API with pagination. The first page
+// tells how many pages there are, the rest are fetched in concurrent batches
+// until the limits are reached or enough is satisfied. Stopping at MaxPages
+// before enough is satisfied returns an error wrapping ErrTagsTruncated.
+func (c *Client) getDockerHubTags(ctx context.Context, url string, enough func([]Tag) bool) ([]Tag, error) {
+	opts := c.dockerHub
+
+	first, err := c.getDockerHubPage(ctx, url, 1)
+	if err != nil {
+		return nil, err
+	}
+	tags := dockerHubPageTags(first)
+
+	total := (first.Count + opts.PageSize - 1) / opts.PageSize
+	pages := total
+	if opts.MaxPages > 0 && pages > opts.MaxPages {
+		pages = opts.MaxPages
+	}
+	done := func() bool {
+		return (opts.MaxTags > 0 && len(tags) >= opts.MaxTags) || (enough != nil && enough(tags))
+	}
+
+	for start := 2; start <= pages && !done(); start += opts.Concurrency {
+		end := min(start+opts.Concurrency-1, pages)
+		batch := make([]*DockerHubTagsResponse, end-start+1)
+		errs := make([]error, len(batch))
+
+		var wg sync.WaitGroup
+		for i := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				batch[i], errs[i] = c.getDockerHubPage(ctx, url, start+i)
+			}()
+		}
+		wg.Wait()
+
+		// keep the API order, newest tags first
+		for i, page := range batch {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			tags = append(tags, dockerHubPageTags(page)...)
+		}
+	}
+
+	if pages < total && !done() {
+		return tags, fmt.Errorf("%w: listed %d of %d pages", ErrTagsTruncated, pages, total)
+	}
+	if opts.MaxTags > 0 && len(tags) > opts.MaxTags {
+		tags = tags[:opts.MaxTags]
+	}
+	return tags, nil
+}
+
+func (c *Client) getDockerHubPage(ctx context.Context, url string, page int) (*DockerHubTagsResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s&page=%d", url, page), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker hub: listing tags failed: %s", resp.Status)
+	}
+
+	var dockerResp DockerHubTagsResponse
+	if err := json.Unmarshal(body, &dockerResp); err != nil {
+		return nil, err
+	}
+	return &dockerResp, nil
+}
+
+func dockerHubPageTags(page *DockerHubTagsResponse) []Tag {
+	tags := make([]Tag, 0, len(page.Results))
+	for _, r := range page.Results {
+		tag := Tag{
+			Name:      r.Name,
+			Version:   parseVersion(r.Name),
+			Published: r.LastUpdated,
+			Digest:    r.Digest,
+		}
+		for _, img := range r.Images {
+			tag.Platforms = append(tag.Platforms, platformName(img.OS, img.Architecture, img.Variant))
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// NameFilter returns a substring every upgrade candidate of image pinned to
+// current must contain, its flavor like alpine or the prefix of its configured
+// stream, or an empty string when any tag may be a candidate
+func (c *Client) NameFilter(image, current, version string) string {
+	if variant := c.Variant(image, current); variant != "" {
+		return variant
+	}
+	if stream := c.Stream(image, version); stream != "" {
+		return stream + "."
+	}
+	return ""
+}
+
+// NewerCandidates returns a stop condition for ListTagsUntil that is met once
+// n tags of image share the flavor of current and have a greater version
+// according to scheme. n <= 0 never stops early.
+func (c *Client) NewerCandidates(image, current string, scheme VersionScheme, n int) func([]Tag) bool {
+	if n <= 0 {
+		return nil
+	}
+	currentVersion := scheme(current)
+	variant := c.Variant(image, current)
+	return func(tags []Tag) bool {
+		if _, ok := splitVersion(currentVersion); !ok {
+			return false
+		}
+		found := 0
+		for _, t := range tags {
+			if c.Variant(image, t.Name) == variant && compareVersions(scheme(t.Name), currentVersion) > 0 {
+				found++
+			}
+		}
+		return found >= n
+	}
+}
@@ -0,0 +1,140 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeHub serves count tags newest first like the Docker Hub tags API, each
+// request taking latency to model the round trip
+type fakeHub struct {
+	count    int
+	latency  time.Duration
+	requests atomic.Int64
+}
+
+func (h *fakeHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests.Add(1)
+	time.Sleep(h.latency)
+	q := r.URL.Query()
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	page, _ := strconv.Atoi(q.Get("page"))
+	var resp DockerHubTagsResponse
+	resp.Count = h.count
+	for i := (page - 1) * pageSize; i < min(page*pageSize, h.count); i++ {
+		resp.Results = append(resp.Results, struct {
+			Name        string    `json:"name"`
+			LastUpdated time.Time `json:"tag_last_pushed"`
+			Digest      string    `json:"digest"`
+			Images      []struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant"`
+			} `json:"images"`
+		}{Name: fmt.Sprintf("%d.%d.%d", 16-i/1000, (i/100)%10, i%100)})
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// redirect sends every request to the test server
+type redirect struct{ target *url.URL }
+
+func (t redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newHubClient(tb testing.TB, hub *fakeHub, opts DockerHubOptions) *Client {
+	srv := httptest.NewServer(hub)
+	tb.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	c := &Client{httpClient: &http.Client{Transport: redirect{target}}, registries: map[string]Registry{}}
+	c.SetDockerHubOptions(opts)
+	return c
+}
+
+// TestDockerHubDefaults checks that the default bounds, DOCKERHUB_MAX_PAGES=10
+// of DOCKERHUB_PAGE_SIZE=100 tags fetched 4 at a time, list the 1000 newest
+// tags of a large repository in 10 requests, flagged as truncated
+func TestDockerHubDefaults(t *testing.T) {
+	hub := &fakeHub{count: 5000}
+	c := newHubClient(t, hub, DockerHubOptions{MaxPages: 10, Concurrency: 4, PageSize: 100})
+	tags, err := c.ListTags(context.Background(), "docker.io", "library/postgres")
+	if !errors.Is(err, ErrTagsTruncated) {
+		t.Fatalf("got %v, want ErrTagsTruncated", err)
+	}
+	if len(tags) != 1000 || hub.requests.Load() != 10 {
+		t.Errorf("listed %d tags in %d requests, want 1000 in 10", len(tags), hub.requests.Load())
+	}
+	if tags[0].Name != "16.0.0" {
+		t.Errorf("first tag is %s, want the newest one", tags[0].Name)
+	}
+}
+
+func TestDockerHubTruncation(t *testing.T) {
+	// listed is satisfied once the tag name is listed
+	listed := func(name string) func([]Tag) bool {
+		return func(tags []Tag) bool {
+			return slices.ContainsFunc(tags, func(t Tag) bool { return t.Name == name })
+		}
+	}
+	tests := []struct {
+		name      string
+		count     int
+		enough    func([]Tag) bool
+		truncated bool
+	}{
+		{name: "all pages listed", count: 1000},
+		{name: "pages past the limit", count: 1001, truncated: true},
+		{name: "stopped early", count: 5000, enough: listed("16.1.99")},
+		{name: "stopped at the limit", count: 5000, enough: listed("16.9.99")},
+		{name: "not enough at the limit", count: 5000, enough: listed("15.0.99"), truncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newHubClient(t, &fakeHub{count: tt.count}, DockerHubOptions{MaxPages: 10, Concurrency: 4, PageSize: 100})
+			_, err := c.ListTagsUntil(context.Background(), "docker.io", "library/postgres", tt.enough)
+			if truncated := errors.Is(err, ErrTagsTruncated); truncated != tt.truncated || (err != nil && !truncated) {
+				t.Errorf("got %v, want truncated %v", err, tt.truncated)
+			}
+		})
+	}
+}
+
+// BenchmarkDockerHubTags lists a repository of 5000 tags, about as many as
+// postgres has, with 10ms round trips. The request count dominates: a page of
+// 100 tags, the largest Docker Hub serves, costs as much as a page of 25, so
+// smaller pages only multiply the requests for the same tags. Ten pages list
+// the 1000 newest tags in about 4 round trips, listing every page takes 5
+// times the requests and 4 times as long for tags too old to be proposed.
+func BenchmarkDockerHubTags(b *testing.B) {
+	for _, pageSize := range []int{25, 50, 100} {
+		for _, maxPages := range []int{1, 5, 10, 0} {
+			b.Run(fmt.Sprintf("page_size=%d/max_pages=%d", pageSize, maxPages), func(b *testing.B) {
+				hub := &fakeHub{count: 5000, latency: 10 * time.Millisecond}
+				c := newHubClient(b, hub, DockerHubOptions{MaxPages: maxPages, Concurrency: 4, PageSize: pageSize})
+				var tags []Tag
+				for b.Loop() {
+					var err error
+					if tags, err = c.ListTags(context.Background(), "docker.io", "library/postgres"); err != nil && !errors.Is(err, ErrTagsTruncated) {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(hub.requests.Load())/float64(b.N), "requests/op")
+				b.ReportMetric(float64(len(tags)), "tags/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+// defaultEOLProducts maps images to their endoflife.date product, clients
+// extend them with the configured ones
+var defaultEOLProducts = []config.EOLConfig{
+	{Image: "docker.io/library/postgres", Product: "postgresql"},
+	{Image: "docker.io/library/mariadb", Product: "mariadb"},
+	{Image: "docker.io/library/mysql", Product: "mysql"},
+	{Image: "docker.io/library/php", Product: "php"},
+	{Image: "docker.io/library/node", Product: "nodejs"},
+	{Image: "docker.io/library/redis", Product: "redis"},
+	{Image: "docker.io/library/python", Product: "python"},
+	{Image: "docker.io/library/golang", Product: "go"},
+	{Image: "docker.io/library/nginx", Product: "nginx"},
+	{Image: "docker.io/library/alpine", Product: "alpine"},
+	{Image: "docker.io/library/mongo", Product: "mongodb"},
+}
+
+// eolCycle is a release cycle as served by endoflife.date, EOL is either a
+// date or false
+type eolCycle struct {
+	Cycle string          `json:"cycle"`
+	EOL   json.RawMessage `json:"eol"`
+}
+
+// EndOfLife returns the end of life date of the release cycle version of
+// image belongs to, according to endoflife.date. ok is false for images
+// without known product, cycles without a date and versions in no cycle.
+func (c *Client) EndOfLife(ctx context.Context, image, version string) (eol time.Time, ok bool, err error) {
+	product := ""
+	for _, p := range c.eolProducts {
+		if match, _ := path.Match(p.Image, image); match {
+			product = p.Product
+		}
+	}
+	if product == "" || version == "" {
+		return time.Time{}, false, nil
+	}
+	cycles, err := c.productCycles(ctx, product)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	// the most specific cycle wins, 10.11 over 10 for mariadb 10.11.6
+	best := eolCycle{}
+	for _, c := range cycles {
+		if inStream(version, c.Cycle) && len(c.Cycle) > len(best.Cycle) {
+			best = c
+		}
+	}
+	var date string
+	if best.Cycle == "" || json.Unmarshal(best.EOL, &date) != nil {
+		return time.Time{}, false, nil
+	}
+	eol, err = time.Parse(time.DateOnly, date)
+	if err != nil {
+		return time.Time{}, false, nil
+	}
+	return eol, true, nil
+}
+
+// productCycles fetches the release cycles of product once per client
+func (c *Client) productCycles(ctx context.Context, product string) ([]eolCycle, error) {
+	c.eolMu.Lock()
+	defer c.eolMu.Unlock()
+	if cycles, ok := c.eolCycles[product]; ok {
+		return cycles, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://endoflife.date/api/"+product+".json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endoflife.date: fetching %s failed: %s", product, resp.Status)
+	}
+	var cycles []eolCycle
+	if err := json.NewDecoder(resp.Body).Decode(&cycles); err != nil {
+		return nil, fmt.Errorf("endoflife.date: invalid cycles of %s: %w", product, err)
+	}
+	c.eolCycles[product] = cycles
+	return cycles, nil
+}
@@ -0,0 +1,129 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrPlatformMissing is returned when a tag exists but is not published for
+// one of the required platforms
+var ErrPlatformMissing = errors.New("platform not published")
+
+type platformManifest struct {
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Manifests []struct {
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// CheckPullable confirms that tag exists and is published for every platform,
+// e.g. linux/amd64 or linux/arm/v7. Single platform images are checked
+// against their image configuration.
+func (c *Client) CheckPullable(ctx context.Context, registry, repo, tag string, platforms []string) error {
+	reg, repo, err := c.manifestRegistry(registry, repo)
+	if err != nil {
+		return err
+	}
+	var m platformManifest
+	if err := reg.getManifest(ctx, repo, tag, &m); err != nil {
+		return err
+	}
+
+	var published []string
+	for _, p := range m.Manifests {
+		published = append(published, platformName(p.Platform.OS, p.Platform.Architecture, p.Platform.Variant))
+	}
+	if len(m.Manifests) == 0 && m.Config.Digest != "" {
+		data, err := reg.blob(ctx, repo, m.Config.Digest)
+		if err != nil {
+			return err
+		}
+		var imageConfig struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		}
+		if err := json.Unmarshal(data, &imageConfig); err != nil {
+			return fmt.Errorf("%s: invalid image configuration %s: %w", reg.Host, m.Config.Digest, err)
+		}
+		published = append(published, platformName(imageConfig.OS, imageConfig.Architecture, imageConfig.Variant))
+	}
+
+	if missing := missingPlatform(published, platforms); missing != "" {
+		return fmt.Errorf("%s:%s: %w: %s", repo, tag, ErrPlatformMissing, missing)
+	}
+	return nil
+}
+
+// missingPlatform returns the first of platforms not in published, where
+// linux/arm matches linux/arm/v7
+func missingPlatform(published, platforms []string) string {
+	for _, want := range platforms {
+		if !slices.ContainsFunc(published, func(p string) bool { return p == want || strings.HasPrefix(p, want+"/") }) {
+			return want
+		}
+	}
+	return ""
+}
+
+// FilterPlatforms splits tags into those published for every platform, or
+// whose platforms the listing doesn't tell, and the incompatible ones newer
+// than version
+func FilterPlatforms(tags []Tag, platforms []string, version string) ([]Tag, []Tag) {
+	var kept, incompatible []Tag
+	for _, t := range tags {
+		if len(t.Platforms) == 0 || missingPlatform(t.Platforms, platforms) == "" {
+			kept = append(kept, t)
+			continue
+		}
+		if _, ok := splitVersion(version); !ok || compareVersions(t.Version, version) > 0 {
+			incompatible = append(incompatible, t)
+		}
+	}
+	return kept, incompatible
+}
+
+// FirstPullable walks sorted, newest first, and returns the first tag newer
+// than version published for every platform, along with the newer tags
+// skipped because a platform is missing. No tag is returned when none newer
+// than version is pullable. At most limit manifests are inspected, 0 means
+// unlimited.
+func (c *Client) FirstPullable(ctx context.Context, registry, repo string, sorted []Tag, version string, platforms []string, limit int) (*Tag, []Tag, error) {
+	var incompatible []Tag
+	for i, t := range sorted {
+		if _, ok := splitVersion(version); ok && compareVersions(t.Version, version) <= 0 {
+			break
+		}
+		if limit > 0 && i >= limit {
+			return nil, incompatible, fmt.Errorf("none of the %d newest tags is published for %s", limit, strings.Join(platforms, ", "))
+		}
+		err := c.CheckPullable(ctx, registry, repo, t.Name, platforms)
+		if errors.Is(err, ErrPlatformMissing) {
+			incompatible = append(incompatible, t)
+			continue
+		}
+		if err != nil {
+			return nil, incompatible, fmt.Errorf("not proposing %s, it is not pullable: %w", t.Name, err)
+		}
+		return &sorted[i], incompatible, nil
+	}
+	return nil, incompatible, nil
+}
+
+func platformName(os, arch, variant string) string {
+	name := os + "/" + arch
+	if variant != "" {
+		name += "/" + variant
+	}
+	return name
+}
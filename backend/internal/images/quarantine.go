@@ -0,0 +1,88 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+// loadQuarantine fetches a shared deny-list, a JSON array in the same format
+// as the quarantine section of the configuration file
+func (c *Client) loadQuarantine(ctx context.Context, url string) ([]config.QuarantineConfig, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching quarantine list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching quarantine list %s: %s", url, resp.Status)
+	}
+
+	var entries []config.QuarantineConfig
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("error parsing quarantine list %s: %w", url, err)
+	}
+	return entries, nil
+}
+
+// QuarantinedTag is a candidate skipped because it is on the quarantine list
+type QuarantinedTag struct {
+	Tag    Tag
+	Reason string
+}
+
+func (q QuarantinedTag) String() string {
+	if q.Reason == "" {
+		return q.Tag.Name
+	}
+	return fmt.Sprintf("%s (%s)", q.Tag.Name, q.Reason)
+}
+
+// quarantined returns the entry matching tag of image, entries match either
+// the tag name or its parsed version
+func (c *Client) quarantined(image string, t Tag) (config.QuarantineConfig, bool) {
+	for _, q := range c.quarantine {
+		if ok, _ := path.Match(q.Image, image); !ok {
+			continue
+		}
+		if q.Version == t.Name || (t.Version != "" && q.Version == t.Version) {
+			return q, true
+		}
+	}
+	return config.QuarantineConfig{}, false
+}
+
+// FilterQuarantined splits tags into the ones that may be proposed and the
+// quarantined ones
+func (c *Client) FilterQuarantined(image string, tags []Tag) ([]Tag, []QuarantinedTag) {
+	var kept []Tag
+	var skipped []QuarantinedTag
+	for _, t := range tags {
+		if q, ok := c.quarantined(image, t); ok {
+			skipped = append(skipped, QuarantinedTag{Tag: t, Reason: q.Reason})
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept, skipped
+}
+
+// NewerQuarantined returns the quarantined tags newer than version, i.e. the
+// upgrades that were held back
+func NewerQuarantined(skipped []QuarantinedTag, version string) []QuarantinedTag {
+	var newer []QuarantinedTag
+	for _, q := range skipped {
+		if _, ok := splitVersion(version); !ok || compareVersions(q.Tag.Version, version) > 0 {
+			newer = append(newer, q)
+		}
+	}
+	return newer
+}
@@ -0,0 +1,45 @@
+package images
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+func TestFilterQuarantined(t *testing.T) {
+	c := newTestClient(t, &config.Config{Quarantine: []config.QuarantineConfig{
+		{Image: "docker.io/library/postgres", Version: "15.5", Reason: "data loss"},
+		{Image: "docker.io/library/*", Version: "16.1-alpine"},
+		{Image: "ghcr.io/*", Version: "15.6"},
+	}})
+	tags := []Tag{
+		{Name: "15.4", Version: "15.4"},
+		// matched by name or by parsed version
+		{Name: "15.5", Version: "15.5"},
+		{Name: "v15.5-bookworm", Version: "15.5"},
+		{Name: "16.1-alpine", Version: "16.1"},
+		{Name: "16.1", Version: "16.1"},
+		// entries of other images don't apply
+		{Name: "15.6", Version: "15.6"},
+	}
+	kept, skipped := c.FilterQuarantined("docker.io/library/postgres", tags)
+	if got, want := tagNames(kept), []string{"15.4", "16.1", "15.6"}; !slices.Equal(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+	var got []string
+	for _, q := range skipped {
+		got = append(got, q.String())
+	}
+	if want := []string{"15.5 (data loss)", "v15.5-bookworm (data loss)", "16.1-alpine"}; !slices.Equal(got, want) {
+		t.Errorf("skipped %v, want %v", got, want)
+	}
+
+	newer := NewerQuarantined(skipped, "15.5")
+	if len(newer) != 1 || newer[0].Tag.Name != "16.1-alpine" {
+		t.Errorf("NewerQuarantined(15.5) = %v, want 16.1-alpine", newer)
+	}
+	if newer := NewerQuarantined(skipped, "latest"); len(newer) != len(skipped) {
+		t.Errorf("NewerQuarantined(latest) = %v, want every skipped tag", newer)
+	}
+}
@@ -0,0 +1,74 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// quayPageSize is the largest page the Quay API serves
+const quayPageSize = 100
+
+type quayTagsResponse struct {
+	Tags []struct {
+		Name         string `json:"name"`
+		LastModified string `json:"last_modified"`
+		// Expiration is set on tags scheduled for removal
+		Expiration string `json:"expiration"`
+	} `json:"tags"`
+	HasAdditional bool `json:"has_additional"`
+}
+
+// errQuayAPIUnavailable makes ListTagsUntil fall back to the v2 listing, e.g.
+// for private repositories the anonymous API call can't see
+var errQuayAPIUnavailable = errors.New("quay API unavailable")
+
+// getQuayTags lists the active tags of repo through the Quay REST API, which
+// unlike the v2 listing tells when they were pushed and skips expired ones.
+// Paging stops once enough reports true.
+func (c *Client) getQuayTags(ctx context.Context, repo string, enough func([]Tag) bool) ([]Tag, error) {
+	var tags []Tag
+	now := time.Now()
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("https://quay.io/api/v1/repository/%s/tag/?onlyActiveTags=true&limit=%d&page=%d", repo, quayPageSize, page)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var body quayTagsResponse
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, errQuayAPIUnavailable
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			return nil, fmt.Errorf("quay.io: listing tags failed: %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("quay.io: invalid tag listing: %w", err)
+		}
+
+		for _, t := range body.Tags {
+			if expires, err := time.Parse(time.RFC1123Z, t.Expiration); err == nil && expires.Before(now) {
+				continue
+			}
+			tag := Tag{Name: t.Name, Version: parseVersion(t.Name)}
+			if modified, err := time.Parse(time.RFC1123Z, t.LastModified); err == nil {
+				tag.Published = modified
+			}
+			tags = append(tags, tag)
+		}
+		if !body.HasAdditional || (enough != nil && enough(tags)) {
+			return tags, nil
+		}
+	}
+}
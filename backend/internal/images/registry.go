@@ -0,0 +1,310 @@
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrManifestNotFound is returned when no manifest is published under a tag
+// or digest
+var ErrManifestNotFound = errors.New("manifest not found")
+
+// Registry is an OCI Distribution v2 registry
+type Registry struct {
+	Host      string
+	PlainHTTP bool
+	// Auth answers authentication challenges, nil means anonymous
+	Auth Authenticator
+	// PageSize asks for this many tags per page, 0 leaves it to the
+	// registry, and MaxTags stops the listing, 0 means unlimited
+	PageSize int
+	MaxTags  int
+
+	// client is the HTTP client of the Client the registry belongs to
+	client *http.Client
+}
+
+// defaultRegistries are reachable through the generic v2 client without
+// configuration, Docker Hub is handled separately through its own API
+var defaultRegistries = []Registry{
+	{Host: "ghcr.io"},
+	{Host: "quay.io"},
+	{Host: "registry.k8s.io"},
+}
+
+func (r Registry) baseURL() string {
+	if r.PlainHTTP {
+		return "http://" + r.Host
+	}
+	return "https://" + r.Host
+}
+
+// do performs a request against the registry, answering bearer and basic
+// authentication challenges
+func (r Registry) do(ctx context.Context, method, rawURL string, accept ...string) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accept {
+			req.Header.Add("Accept", a)
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	req, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "bearer":
+		token, err := r.fetchToken(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "basic":
+		if r.Auth == nil {
+			return nil, fmt.Errorf("%s requires credentials", r.Host)
+		}
+		username, password, err := r.Auth.Credentials(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(username, password)
+	default:
+		return nil, fmt.Errorf("%s: unsupported authentication challenge %q", r.Host, challenge)
+	}
+	return r.client.Do(req)
+}
+
+// fetchToken exchanges the configured credentials, if any, for a bearer token
+// at the realm advertised by the registry
+func (r Registry) fetchToken(ctx context.Context, params map[string]string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("%s: bearer challenge without realm", r.Host)
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("%s: invalid token realm %q: %w", r.Host, realm, err)
+	}
+	q := u.Query()
+	for _, key := range []string{"service", "scope"} {
+		if v := params[key]; v != "" {
+			q.Set(key, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if r.Auth != nil {
+		username, password, err := r.Auth.Credentials(ctx)
+		if err != nil {
+			return "", err
+		}
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: token request failed: %s", r.Host, resp.Status)
+	}
+
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("%s: invalid token response: %w", r.Host, err)
+	}
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// parseChallenge splits a WWW-Authenticate header into its lower cased scheme
+// and parameters, e.g. Bearer realm="https://ghcr.io/token",scope="repository:a/b:pull"
+func parseChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	scheme = strings.ToLower(scheme)
+
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(value, `"`) {
+			// quoted values may contain commas, e.g. multiple scope actions
+			end := strings.Index(value[1:], `"`)
+			if end == -1 {
+				params[key] = value[1:]
+				break
+			}
+			params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			v, next, _ := strings.Cut(value, ",")
+			params[key] = strings.TrimSpace(v)
+			rest = next
+		}
+	}
+	return scheme, params
+}
+
+// nextLink returns the absolute URL of the rel="next" Link header, if any
+func nextLink(resp *http.Response) string {
+	for _, link := range resp.Header.Values("Link") {
+		for _, part := range strings.Split(link, ",") {
+			target, rel, ok := strings.Cut(part, ";")
+			if !ok || !strings.Contains(rel, `rel="next"`) {
+				continue
+			}
+			target = strings.Trim(strings.TrimSpace(target), "<>")
+			next, err := resp.Request.URL.Parse(target)
+			if err != nil {
+				return ""
+			}
+			return next.String()
+		}
+	}
+	return ""
+}
+
+// getRegistryTags lists the tags of repo following the v2 pagination links
+func getRegistryTags(ctx context.Context, reg Registry, rawURL string) ([]Tag, error) {
+	tags := []Tag{}
+
+	for rawURL != "" && (reg.MaxTags <= 0 || len(tags) < reg.MaxTags) {
+		resp, err := reg.do(ctx, http.MethodGet, rawURL, "application/json")
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: listing tags failed: %s", reg.Host, resp.Status)
+		}
+
+		var genericResp GenericTagsResponse
+		if err := json.Unmarshal(body, &genericResp); err != nil {
+			return nil, err
+		}
+		for _, t := range genericResp.Tags {
+			tags = append(tags, Tag{
+				Name:    t,
+				Version: parseVersion(t),
+			})
+		}
+
+		rawURL = nextLink(resp)
+	}
+
+	return tags, nil
+}
+
+// getManifest decodes the manifest published under reference into v
+func (r Registry) getManifest(ctx context.Context, repo, reference string, v any) error {
+	resp, err := r.do(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/manifests/%s", r.baseURL(), repo, reference), manifestMediaTypes...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s:%s: %w", repo, reference, ErrManifestNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: fetching manifest %s:%s failed: %s", r.Host, repo, reference, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: invalid manifest %s:%s: %w", r.Host, repo, reference, err)
+	}
+	return nil
+}
+
+// blob downloads a blob and checks it against its digest
+func (r Registry) blob(ctx context.Context, repo, digest string) ([]byte, error) {
+	resp, err := r.do(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/blobs/%s", r.baseURL(), repo, digest))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: fetching blob %s failed: %s", r.Host, digest, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("%s: blob %s does not match its digest", r.Host, digest)
+	}
+	return data, nil
+}
+
+// Ping checks that the registry at host answers, with the configured
+// credentials when it asks for them
+func (c *Client) Ping(ctx context.Context, host string) error {
+	var resp *http.Response
+	var err error
+	if host == "docker.io" {
+		// tags are listed through the Hub API rather than the registry
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, "https://hub.docker.com/v2/repositories/library/alpine/tags?page_size=1", nil); err != nil {
+			return err
+		}
+		resp, err = c.httpClient.Do(req)
+	} else {
+		r, ok := c.lookupRegistry(host)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnsupportedRegistry, host)
+		}
+		resp, err = r.do(ctx, http.MethodGet, r.baseURL()+"/v2/")
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s answered %s", host, resp.Status)
+	}
+	return nil
+}
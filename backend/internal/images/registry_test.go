@@ -0,0 +1,63 @@
+package images
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		header string
+		scheme string
+		params map[string]string
+	}{
+		{
+			header: `Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:nethserver/mail:pull"`,
+			scheme: "bearer",
+			params: map[string]string{"realm": "https://ghcr.io/token", "service": "ghcr.io", "scope": "repository:nethserver/mail:pull"},
+		},
+		{
+			// quoted values may hold commas
+			header: `Bearer realm="https://auth.docker.io/token",scope="repository:library/postgres:pull,push"`,
+			scheme: "bearer",
+			params: map[string]string{"realm": "https://auth.docker.io/token", "scope": "repository:library/postgres:pull,push"},
+		},
+		{
+			header: `Basic realm="Registry Realm"`,
+			scheme: "basic",
+			params: map[string]string{"realm": "Registry Realm"},
+		},
+		{
+			// unquoted values, spaces after commas and upper case keys
+			header: `BEARER Realm=https://quay.io/v2/auth, Service=quay.io`,
+			scheme: "bearer",
+			params: map[string]string{"realm": "https://quay.io/v2/auth", "service": "quay.io"},
+		},
+		{
+			header: `  Bearer realm="https://ghcr.io/token"  `,
+			scheme: "bearer",
+			params: map[string]string{"realm": "https://ghcr.io/token"},
+		},
+		{
+			header: `Bearer realm="https://ghcr.io/token`,
+			scheme: "bearer",
+			params: map[string]string{"realm": "https://ghcr.io/token"},
+		},
+		{
+			header: `Negotiate`,
+			scheme: "negotiate",
+			params: map[string]string{},
+		},
+		{
+			header: ``,
+			scheme: "",
+			params: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		scheme, params := parseChallenge(tt.header)
+		if scheme != tt.scheme || !maps.Equal(params, tt.params) {
+			t.Errorf("parseChallenge(%q) = %q, %v, want %q, %v", tt.header, scheme, params, tt.scheme, tt.params)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package images
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+// VersionScheme extracts a dotted numeric version from a tag, returning an
+// empty string for tags that don't follow the scheme
+type VersionScheme func(tag string) string
+
+var (
+	calverRegex  = regexp.MustCompile(`(\d{4})[.-](\d{1,2})(?:[.-](\d+))?`)
+	numericRegex = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)`)
+)
+
+// VersionSchemes lists the schemes selectable in the configuration
+var VersionSchemes = map[string]VersionScheme{
+	// semver: 1.2.3, v1.2.3-alpine
+	"semver": parseVersion,
+	// calver: 2024.05.1, 2024-05
+	"calver": func(tag string) string {
+		m := calverRegex.FindStringSubmatch(tag)
+		if m == nil {
+			return ""
+		}
+		return normalizeVersion(m[1:]...)
+	},
+	// numeric: any number of components at the start, 28.0.4-apache, 1.25
+	"numeric": func(tag string) string {
+		m := numericRegex.FindStringSubmatch(tag)
+		if m == nil {
+			return ""
+		}
+		return normalizeVersion(strings.Split(m[1], ".")...)
+	},
+	// loose: the first version found anywhere, version-15.0.2
+	"loose": func(tag string) string {
+		v := dottedVersionToken.FindString(tag)
+		if v == "" {
+			v = numericToken.FindString(tag)
+		}
+		return normalizeVersion(strings.Split(strings.TrimPrefix(v, "v"), ".")...)
+	},
+}
+
+// normalizeVersion joins numeric components, dropping leading zeros and
+// empty trailing components. Any non numeric component yields "".
+func normalizeVersion(parts ...string) string {
+	nums := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return ""
+		}
+		nums = append(nums, strconv.Itoa(n))
+	}
+	return strings.Join(nums, ".")
+}
+
+type schemeRule struct {
+	pattern string
+	scheme  VersionScheme
+}
+
+func (c *Client) addSchemeRule(sc config.SchemeConfig) error {
+	scheme, ok := VersionSchemes[sc.Scheme]
+	if !ok {
+		return fmt.Errorf("version scheme for %s: unknown scheme %q", sc.Image, sc.Scheme)
+	}
+	c.schemeRules = append(c.schemeRules, schemeRule{pattern: sc.Image, scheme: scheme})
+	return nil
+}
+
+// SchemeFor returns the version scheme configured for image, semver by
+// default
+func (c *Client) SchemeFor(image string) VersionScheme {
+	for _, r := range c.schemeRules {
+		if ok, _ := path.Match(r.pattern, image); ok {
+			return r.scheme
+		}
+	}
+	return parseVersion
+}
+
+// ParseVersions sets the version of every tag according to scheme
+func ParseVersions(scheme VersionScheme, tags []Tag) []Tag {
+	parsed := make([]Tag, len(tags))
+	for i, t := range tags {
+		t.Version = scheme(t.Name)
+		parsed[i] = t
+	}
+	return parsed
+}
@@ -0,0 +1,66 @@
+package images
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+func TestVersionSchemes(t *testing.T) {
+	tests := []struct {
+		scheme, tag, want string
+	}{
+		{"semver", "1.2.3", "1.2.3"},
+		{"semver", "v1.2.3-alpine", "1.2.3"},
+		{"semver", "1.25", ""},
+		{"semver", "latest", ""},
+		{"calver", "2024.05.1", "2024.5.1"},
+		{"calver", "2024-05", "2024.5"},
+		{"calver", "RELEASE.2024-05-01", "2024.5.1"},
+		{"calver", "24.04", ""},
+		{"numeric", "28.0.4-apache", "28.0.4"},
+		{"numeric", "v1.25", "1.25"},
+		{"numeric", "16", "16"},
+		{"numeric", "alpine-3.19", ""},
+		{"loose", "version-15.0.2", "15.0.2"},
+		{"loose", "r20", "20"},
+		{"loose", "v007", "7"},
+		{"loose", "latest", ""},
+	}
+	for _, tt := range tests {
+		if got := VersionSchemes[tt.scheme](tt.tag); got != tt.want {
+			t.Errorf("%s(%s) = %q, want %q", tt.scheme, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestSchemeFor(t *testing.T) {
+	c := newTestClient(t, &config.Config{Schemes: []config.SchemeConfig{
+		{Image: "docker.io/minio/minio", Scheme: "calver"},
+		{Image: "docker.io/library/*", Scheme: "numeric"},
+		{Image: "docker.io/library/postgres", Scheme: "loose"},
+	}})
+	tests := []struct {
+		image, tag, want string
+	}{
+		{"docker.io/minio/minio", "RELEASE.2024-05-01T01-11-10Z", "2024.5.1"},
+		// the first matching rule wins
+		{"docker.io/library/postgres", "16", "16"},
+		{"docker.io/library/nginx", "1.25-alpine", "1.25"},
+		// semver by default
+		{"ghcr.io/nethserver/mail", "1.25", ""},
+		{"ghcr.io/nethserver/mail", "v1.2.3", "1.2.3"},
+	}
+	for _, tt := range tests {
+		if got := c.SchemeFor(tt.image)(tt.tag); got != tt.want {
+			t.Errorf("SchemeFor(%s)(%s) = %q, want %q", tt.image, tt.tag, got, tt.want)
+		}
+	}
+
+	_, err := NewClient(context.Background(), &config.Config{Schemes: []config.SchemeConfig{{Image: "*", Scheme: "romver"}}})
+	if err == nil || !strings.Contains(err.Error(), `unknown scheme "romver"`) {
+		t.Errorf("got %v, want an unknown scheme error", err)
+	}
+}
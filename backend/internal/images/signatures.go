@@ -0,0 +1,146 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	mediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerImage = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+var manifestMediaTypes = []string{mediaTypeOCIIndex, mediaTypeOCIManifest, mediaTypeDockerList, mediaTypeDockerImage}
+
+// dockerHubHost serves docker.io manifests, Docker Hub's own API only lists
+// tags
+const dockerHubHost = "registry-1.docker.io"
+
+// manifestRegistry returns the v2 endpoint serving manifests of registry
+func (c *Client) manifestRegistry(registry, repo string) (Registry, string, error) {
+	if registry == "docker.io" {
+		if !strings.Contains(repo, "/") {
+			repo = "library/" + repo
+		}
+		return Registry{Host: dockerHubHost, client: c.httpClient}, repo, nil
+	}
+	reg, ok := c.lookupRegistry(registry)
+	if !ok {
+		return Registry{}, "", fmt.Errorf("%w: %s", ErrUnsupportedRegistry, registry)
+	}
+	return reg, repo, nil
+}
+
+// Digest returns the content digest the tag currently points to
+func (c *Client) Digest(ctx context.Context, registry, repo, tag string) (string, error) {
+	reg, repo, err := c.manifestRegistry(registry, repo)
+	if err != nil {
+		return "", err
+	}
+	return reg.digest(ctx, repo, tag)
+}
+
+func (r Registry) digest(ctx context.Context, repo, reference string) (string, error) {
+	resp, err := r.do(ctx, http.MethodHead, fmt.Sprintf("%s/v2/%s/manifests/%s", r.baseURL(), repo, reference), manifestMediaTypes...)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: resolving %s:%s failed: %s", r.Host, repo, reference, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("%s: no digest returned for %s:%s", r.Host, repo, reference)
+	}
+	return digest, nil
+}
+
+// exists reports whether a manifest is published under reference
+func (r Registry) exists(ctx context.Context, repo, reference string) (bool, error) {
+	resp, err := r.do(ctx, http.MethodHead, fmt.Sprintf("%s/v2/%s/manifests/%s", r.baseURL(), repo, reference), manifestMediaTypes...)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%s: checking %s:%s failed: %s", r.Host, repo, reference, resp.Status)
+	}
+}
+
+// hasReferrers reports whether the OCI referrers API lists any artifact, like
+// a signature or attestation, attached to digest. Registries without the
+// referrers API answer 404.
+func (r Registry) hasReferrers(ctx context.Context, repo, digest string) (bool, error) {
+	resp, err := r.do(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/referrers/%s", r.baseURL(), repo, digest), mediaTypeOCIIndex)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s: listing referrers of %s@%s failed: %s", r.Host, repo, digest, resp.Status)
+	}
+
+	var index struct {
+		Manifests []json.RawMessage `json:"manifests"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return false, fmt.Errorf("%s: invalid referrers response: %w", r.Host, err)
+	}
+	return len(index.Manifests) > 0, nil
+}
+
+// IsSigned reports whether tag has a cosign signature, published under the
+// sha256-<digest>.sig tag, or any artifact attached through the OCI referrers
+// API. The signature itself is not verified.
+func (c *Client) IsSigned(ctx context.Context, registry, repo, tag string) (bool, error) {
+	reg, repo, err := c.manifestRegistry(registry, repo)
+	if err != nil {
+		return false, err
+	}
+	digest, err := reg.digest(ctx, repo, tag)
+	if err != nil {
+		return false, err
+	}
+
+	algo, hex, ok := strings.Cut(digest, ":")
+	if !ok {
+		return false, fmt.Errorf("%s: malformed digest %q", reg.Host, digest)
+	}
+	signed, err := reg.exists(ctx, repo, algo+"-"+hex+".sig")
+	if err != nil || signed {
+		return signed, err
+	}
+	return reg.hasReferrers(ctx, repo, digest)
+}
+
+// LatestSigned returns the newest signed tag among tags, checking at most
+// limit candidates, or nil when none of them is signed
+func (c *Client) LatestSigned(ctx context.Context, registry, repo string, tags []Tag, limit int) (*Tag, error) {
+	for i, t := range SortByVersion(tags) {
+		if limit > 0 && i >= limit {
+			break
+		}
+		signed, err := c.IsSigned(ctx, registry, repo, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		if signed {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
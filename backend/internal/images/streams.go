@@ -0,0 +1,47 @@
+package images
+
+import (
+	"path"
+	"strings"
+)
+
+// Stream returns the configured stream of image that version belongs to, the
+// longest matching prefix of the first rule matching image, or an empty
+// string when version is in no declared stream
+func (c *Client) Stream(image, version string) string {
+	for _, s := range c.streams {
+		if ok, _ := path.Match(s.Image, image); !ok {
+			continue
+		}
+		best := ""
+		for _, prefix := range s.Streams {
+			if inStream(version, prefix) && len(prefix) > len(best) {
+				best = prefix
+			}
+		}
+		return best
+	}
+	return ""
+}
+
+// inStream reports whether the dotted version starts with the components of
+// prefix, so that 15.4 is in stream 15 but 150.1 is not
+func inStream(version, prefix string) bool {
+	return version == prefix || strings.HasPrefix(version, prefix+".")
+}
+
+// FilterStream keeps the tags in the stream of the current version, so that
+// a postgres pin on 15 is not upgraded to 16 when both streams are supported
+func (c *Client) FilterStream(image, current string, tags []Tag) []Tag {
+	stream := c.Stream(image, current)
+	if stream == "" {
+		return tags
+	}
+	var kept []Tag
+	for _, t := range tags {
+		if inStream(t.Version, stream) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,57 @@
+package images
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+func TestStream(t *testing.T) {
+	c := newTestClient(t, &config.Config{Streams: []config.StreamConfig{
+		{Image: "docker.io/library/postgres", Streams: []string{"15", "16", "16.2"}},
+		{Image: "docker.io/library/*", Streams: []string{"1"}},
+	}})
+	tests := []struct {
+		image, version, want string
+	}{
+		{"docker.io/library/postgres", "15.4", "15"},
+		{"docker.io/library/postgres", "15", "15"},
+		// the longest prefix wins
+		{"docker.io/library/postgres", "16.2.1", "16.2"},
+		{"docker.io/library/postgres", "16.1", "16"},
+		// components are compared whole
+		{"docker.io/library/postgres", "150.1", ""},
+		{"docker.io/library/postgres", "17.0", ""},
+		// the first matching rule wins even without matching stream
+		{"docker.io/library/postgres", "1.2", ""},
+		{"docker.io/library/redis", "1.2", "1"},
+		{"ghcr.io/nethserver/mail", "15.4", ""},
+	}
+	for _, tt := range tests {
+		if got := c.Stream(tt.image, tt.version); got != tt.want {
+			t.Errorf("Stream(%s, %s) = %q, want %q", tt.image, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestFilterStream(t *testing.T) {
+	c := newTestClient(t, &config.Config{Streams: []config.StreamConfig{
+		{Image: "docker.io/library/postgres", Streams: []string{"15", "16"}},
+	}})
+	tags := []Tag{{Name: "15.5", Version: "15.5"}, {Name: "16.1", Version: "16.1"}, {Name: "17.0", Version: "17.0"}, {Name: "latest"}}
+	tests := []struct {
+		current string
+		want    []string
+	}{
+		{"15.4", []string{"15.5"}},
+		{"16.0", []string{"16.1"}},
+		// outside the declared streams nothing is filtered
+		{"14.9", []string{"15.5", "16.1", "17.0", "latest"}},
+	}
+	for _, tt := range tests {
+		if got := tagNames(c.FilterStream("docker.io/library/postgres", tt.current, tags)); !slices.Equal(got, tt.want) {
+			t.Errorf("FilterStream(%s) = %v, want %v", tt.current, got, tt.want)
+		}
+	}
+}
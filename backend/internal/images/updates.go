@@ -1,30 +1,50 @@
 package images
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// ErrUnsupportedRegistry is returned for images hosted on a registry that is
+// neither built in nor configured
+var ErrUnsupportedRegistry = errors.New("unsupported registry")
+
 // Tag represents a single image tag with optional semantic version
 type Tag struct {
 	Name    string `json:"name"`              // Raw tag name
 	Version string `json:"version,omitempty"` // Parsed semantic version, if available
+	// Published is when the tag was last pushed, Digest what it points to
+	// and Platforms, e.g. linux/arm64, what it is built for. They are only
+	// set when the registry listing tells.
+	Published time.Time `json:"published,omitzero"`
+	Digest    string    `json:"digest,omitempty"`
+	Platforms []string  `json:"platforms,omitempty"`
 }
 
 // DockerHubTagsResponse represents Docker Hub API response
 type DockerHubTagsResponse struct {
 	Results []struct {
-		Name string `json:"name"`
+		Name        string    `json:"name"`
+		LastUpdated time.Time `json:"tag_last_pushed"`
+		Digest      string    `json:"digest"`
+		Images      []struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		} `json:"images"`
 	} `json:"results"`
-	Next string `json:"next"`
+	Next  string `json:"next"`
+	Count int    `json:"count"`
 }
 
-// GenericTagsResponse represents GHCR, Quay, K8s style response
+// GenericTagsResponse represents the OCI Distribution v2 tags list response
 type GenericTagsResponse struct {
 	Name string   `json:"name"`
 	Tags []string `json:"tags"`
@@ -42,48 +62,43 @@ func parseVersion(tag string) string {
 	return ""
 }
 
-// baseURLGenerator returns the API endpoint for a registry/repo
-func baseURLGenerator(registry, repo string) string {
-	switch registry {
-	case "docker.io":
-		return fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/tags?page_size=100", repo)
-	case "ghcr.io":
-		return fmt.Sprintf("https://ghcr.io/v2/%s/tags/list", repo)
-	case "quay.io":
-		return fmt.Sprintf("https://quay.io/v2/%s/tags/list", repo)
-	case "registry.k8s.io":
-		return fmt.Sprintf("https://registry.k8s.io/v2/%s/tags/list", repo)
-	default:
-		fmt.Printf("registry unsupported")
-		return ""
+// baseURLGenerator returns the API endpoint for a registry/repo, or an error
+// wrapping ErrUnsupportedRegistry
+func (c *Client) baseURLGenerator(registry, repo string) (string, error) {
+	if registry == "docker.io" {
+		tagsURL := fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/tags?page_size=%d", repo, c.dockerHub.PageSize)
+		if c.dockerHub.Ordering != "" {
+			tagsURL += "&ordering=" + c.dockerHub.Ordering
+		}
+		return tagsURL, nil
+	}
+	reg, ok := c.lookupRegistry(registry)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedRegistry, registry)
+	}
+	if reg.PageSize > 0 {
+		return fmt.Sprintf("%s/v2/%s/tags/list?n=%d", reg.baseURL(), repo, reg.PageSize), nil
 	}
+	return fmt.Sprintf("%s/v2/%s/tags/list", reg.baseURL(), repo), nil
 }
 
+// FindNearestUpgrade returns the smallest tag whose version is greater than
+// current, versions being dotted numbers as produced by a VersionScheme
 func FindNearestUpgrade(current string, tags []Tag) *Tag {
-	currMaj, currMin, currPat, ok := parseSemver(current)
-	if !ok {
+	if _, ok := splitVersion(current); !ok {
 		return nil
 	}
 
 	var best *Tag
 
 	for _, t := range tags {
-		maj, min, pat, ok := parseSemver(t.Version)
-		if !ok {
+		if _, ok := splitVersion(t.Version); !ok {
 			continue
 		}
 
-		if greater(maj, min, pat, currMaj, currMin, currPat) {
-			if best == nil {
-				tmp := t
-				best = &tmp
-				continue
-			}
-
-			bMaj, bMin, bPat, _ := parseSemver(best.Version)
-
+		if compareVersions(t.Version, current) > 0 {
 			// keep the smallest version that is still greater
-			if greater(bMaj, bMin, bPat, maj, min, pat) {
+			if best == nil || compareVersions(best.Version, t.Version) > 0 {
 				tmp := t
 				best = &tmp
 			}
@@ -93,169 +108,192 @@ func FindNearestUpgrade(current string, tags []Tag) *Tag {
 	return best
 }
 
-// GetImageUpdates fetches tags for a given registry and repo
-func GetImageUpdates(registry, repo string) ([]Tag, error) {
-	baseURL := baseURLGenerator(registry, repo)
-	if baseURL == "" {
-		return nil, fmt.Errorf("unsupported registry: %s", registry)
-	}
-	var (
-		tags []Tag
-		err  error
-	)
-
-	switch registry {
-	case "docker.io":
-		tags, err = getDockerHubTags(baseURL)
-	default:
-		tags, err = getGenericTags(baseURL)
-	}
-
+// GetImageUpdates fetches tags for a given registry and repo and returns the
+// latest one. Images on an unknown registry yield an error wrapping
+// ErrUnsupportedRegistry.
+func (c *Client) GetImageUpdates(ctx context.Context, registry, repo string) ([]Tag, error) {
+	tags, err := c.ListTags(ctx, registry, repo)
 	return filterLatestVersion(tags), err
 }
 
-// getDockerHubTags handles Docker Hub API with pagination
-func getDockerHubTags(url string) ([]Tag, error) {
-	tags := []Tag{}
-	client := &http.Client{}
-
-	for url != "" {
-		resp, err := client.Get(url)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-
-		var dockerResp DockerHubTagsResponse
-		if err := json.Unmarshal(body, &dockerResp); err != nil {
-			return nil, err
-		}
-
-		for _, r := range dockerResp.Results {
-			tags = append(tags, Tag{
-				Name:    r.Name,
-				Version: parseVersion(r.Name),
-			})
-		}
-
-		url = dockerResp.Next // continue to next page
-	}
-
-	return tags, nil
+// ListTags fetches every tag of the given registry and repo
+func (c *Client) ListTags(ctx context.Context, registry, repo string) ([]Tag, error) {
+	return c.ListTagsUntil(ctx, registry, repo, nil)
 }
 
-// getGenericTags handles GHCR, Quay, K8s style APIs
-func getGenericTags(url string) ([]Tag, error) {
-	client := &http.Client{}
-	resp, err := client.Get(url)
+// ListTagsNamed is ListTagsUntil asking Docker Hub for the tags containing
+// name only, see NameFilter. When the filtered listing is empty it falls back
+// to every tag, other registries ignore name.
+func (c *Client) ListTagsNamed(ctx context.Context, registry, repo, name string, enough func([]Tag) bool) ([]Tag, error) {
+	if registry != "docker.io" || name == "" {
+		return c.ListTagsUntil(ctx, registry, repo, enough)
+	}
+	baseURL, err := c.baseURLGenerator(registry, repo)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	tags, err := c.getDockerHubTags(ctx, baseURL+"&name="+url.QueryEscape(name), enough)
+	if err != nil || len(tags) > 0 {
+		return tags, err
+	}
+	return c.ListTagsUntil(ctx, registry, repo, enough)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// ListTagsUntil fetches the tags of the given registry and repo. Registries
+// listing tags over many pages, like Docker Hub, stop early once enough
+// reports true for the tags fetched so far; enough may be nil. When Docker
+// Hub has more pages than allowed the tags of the first ones are returned
+// with an error wrapping ErrTagsTruncated.
+func (c *Client) ListTagsUntil(ctx context.Context, registry, repo string, enough func([]Tag) bool) ([]Tag, error) {
+	baseURL, err := c.baseURLGenerator(registry, repo)
 	if err != nil {
 		return nil, err
 	}
+	var tags []Tag
 
-	var genericResp GenericTagsResponse
-	if err := json.Unmarshal(body, &genericResp); err != nil {
-		return nil, err
+	switch registry {
+	case "docker.io":
+		tags, err = c.getDockerHubTags(ctx, baseURL, enough)
+	case "quay.io":
+		tags, err = c.getQuayTags(ctx, repo, enough)
+		if errors.Is(err, errQuayAPIUnavailable) {
+			reg, _ := c.lookupRegistry(registry)
+			tags, err = getRegistryTags(ctx, reg, baseURL)
+		}
+	default:
+		reg, _ := c.lookupRegistry(registry)
+		tags, err = getRegistryTags(ctx, reg, baseURL)
 	}
 
-	tags := []Tag{}
-	for _, t := range genericResp.Tags {
-		tags = append(tags, Tag{
-			Name:    t,
-			Version: parseVersion(t),
-		})
-	}
+	return tags, err
+}
 
-	return tags, nil
+// SortTags returns every tag, the versioned ones newest first followed by
+// the others in their original order. Unlike SortByVersion no tag is dropped.
+func SortTags(tags []Tag) []Tag {
+	sorted := append([]Tag(nil), tags...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Version, sorted[j].Version
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		return compareVersions(a, b) > 0
+	})
+	return sorted
 }
 
-func filterLatestVersion(tags []Tag) []Tag {
+// SortByVersion returns the tags carrying a semantic version, newest first,
+// keeping a single tag per version
+func SortByVersion(tags []Tag) []Tag {
 	versionMap := map[string]Tag{}
-
 	for _, t := range tags {
 		if t.Version == "" {
-			continue // skip tags without semantic version
+			continue
 		}
 		// Remove architecture prefixes if any (like arm64-)
 		versionParts := strings.Split(t.Version, "-")
-		v := versionParts[len(versionParts)-1]
-
-		versionMap[v] = t
-	}
-
-	if len(versionMap) == 0 {
-		return nil
+		versionMap[versionParts[len(versionParts)-1]] = t
 	}
 
-	// Find the highest version
-	versions := []string{}
+	versions := make([]string, 0, len(versionMap))
 	for v := range versionMap {
 		versions = append(versions, v)
 	}
-
-	// Sort using simple semantic version sort
 	sort.Slice(versions, func(i, j int) bool {
-		return compareSemver(versions[i], versions[j]) > 0 // descending
+		return compareVersions(versions[i], versions[j]) > 0
 	})
 
-	latestVersion := versions[0]
-	return []Tag{versionMap[latestVersion]}
+	sorted := make([]Tag, 0, len(versions))
+	for _, v := range versions {
+		sorted = append(sorted, versionMap[v])
+	}
+	return sorted
 }
 
-// compareSemver compares two semantic versions, returns 1 if v1>v2, -1 if v1<v2, 0 if equal
-func compareSemver(v1, v2 string) int {
-	var major1, minor1, patch1 int
-	var major2, minor2, patch2 int
+func filterLatestVersion(tags []Tag) []Tag {
+	sorted := SortByVersion(tags)
+	if len(sorted) == 0 {
+		return nil
+	}
+	return sorted[:1]
+}
 
-	fmt.Sscanf(v1, "%d.%d.%d", &major1, &minor1, &patch1)
-	fmt.Sscanf(v2, "%d.%d.%d", &major2, &minor2, &patch2)
+// compareVersions compares two dotted versions of any length, missing
+// components count as 0. Returns 1 if v1>v2, -1 if v1<v2, 0 if equal.
+func compareVersions(v1, v2 string) int {
+	a, _ := splitVersion(v1)
+	b, _ := splitVersion(v2)
 
-	if major1 != major2 {
-		if major1 > major2 {
-			return 1
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
 		}
-		return -1
-	}
-	if minor1 != minor2 {
-		if minor1 > minor2 {
-			return 1
+		if i < len(b) {
+			y = b[i]
 		}
-		return -1
-	}
-	if patch1 != patch2 {
-		if patch1 > patch2 {
-			return 1
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
 		}
-		return -1
 	}
 	return 0
 }
 
-func parseSemver(v string) (int, int, int, bool) {
-	var maj, min, pat int
-	_, err := fmt.Sscanf(v, "%d.%d.%d", &maj, &min, &pat)
-	if err != nil {
-		return 0, 0, 0, false
+// Version change levels returned by ChangeLevel
+const (
+	ChangeMajor = "major"
+	ChangeMinor = "minor"
+	ChangePatch = "patch"
+)
+
+// ChangeLevel returns which component differs first between two dotted
+// versions: major, minor or patch for any later component. It returns an
+// empty string when the versions are equal or not comparable.
+func ChangeLevel(from, to string) string {
+	a, okA := splitVersion(from)
+	b, okB := splitVersion(to)
+	if !okA || !okB {
+		return ""
 	}
-	return maj, min, pat, true
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x == y {
+			continue
+		}
+		switch i {
+		case 0:
+			return ChangeMajor
+		case 1:
+			return ChangeMinor
+		default:
+			return ChangePatch
+		}
+	}
+	return ""
 }
 
-func greater(aMaj, aMin, aPat, bMaj, bMin, bPat int) bool {
-	if aMaj != bMaj {
-		return aMaj > bMaj
+// splitVersion returns the numeric components of a dotted version
+func splitVersion(v string) ([]int, bool) {
+	if v == "" {
+		return nil, false
 	}
-	if aMin != bMin {
-		return aMin > bMin
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		nums[i] = n
 	}
-	return aPat > bPat
+	return nums, true
 }
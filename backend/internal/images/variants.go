@@ -0,0 +1,77 @@
+package images
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+// versionToken matches the version part of a tag, dotted versions are
+// preferred so that arm64 or alpine3 are not taken for one
+var (
+	dottedVersionToken = regexp.MustCompile(`v?\d+(?:\.\d+)+`)
+	numericToken       = regexp.MustCompile(`\d+`)
+	variantNoise       = regexp.MustCompile(`[\d.]+`)
+)
+
+type variantRule struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+// addVariantRule compiles a configured rule, its expression must have one
+// capture group returning the variant
+func (c *Client) addVariantRule(vc config.VariantConfig) error {
+	re, err := regexp.Compile(vc.Pattern)
+	if err != nil {
+		return fmt.Errorf("variant rule for %s: %w", vc.Image, err)
+	}
+	if re.NumSubexp() != 1 {
+		return fmt.Errorf("variant rule for %s: %q must have exactly one capture group", vc.Image, vc.Pattern)
+	}
+	c.variantRules = append(c.variantRules, variantRule{pattern: vc.Image, regex: re})
+	return nil
+}
+
+// Variant returns the flavor of tag, e.g. alpine for 1.25-alpine3.19 or
+// fpm-alpine for 8.2-fpm-alpine, and an empty string for plain versions.
+// The first configured rule matching image wins, otherwise the version and
+// any version numbers within the flavor are dropped.
+func (c *Client) Variant(image, tag string) string {
+	for _, r := range c.variantRules {
+		if ok, _ := path.Match(r.pattern, image); !ok {
+			continue
+		}
+		if m := r.regex.FindStringSubmatch(tag); m != nil {
+			return m[1]
+		}
+		return ""
+	}
+
+	loc := dottedVersionToken.FindStringIndex(tag)
+	if loc == nil {
+		loc = numericToken.FindStringIndex(tag)
+	}
+	if loc == nil {
+		return tag
+	}
+	rest := tag[:loc[0]] + "-" + tag[loc[1]:]
+	rest = variantNoise.ReplaceAllString(rest, "")
+	return strings.Trim(rest, "-_")
+}
+
+// FilterVariant keeps the tags sharing the flavor of current, so that an image
+// pinned to an alpine tag is only upgraded to alpine tags
+func (c *Client) FilterVariant(image, current string, tags []Tag) []Tag {
+	want := c.Variant(image, current)
+	var kept []Tag
+	for _, t := range tags {
+		if c.Variant(image, t.Name) == want {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,92 @@
+package images
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+)
+
+// newTestClient returns a client configured by cfg, without network access
+func newTestClient(t *testing.T, cfg *config.Config) *Client {
+	t.Helper()
+	c, err := NewClient(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func tagNames(tags []Tag) []string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func TestVariant(t *testing.T) {
+	c := newTestClient(t, &config.Config{Variants: []config.VariantConfig{
+		{Image: "docker.io/library/nextcloud", Pattern: `-(apache|fpm)$`},
+		{Image: "docker.io/library/*", Pattern: `^\d+-(\w+)$`},
+	}})
+	tests := []struct {
+		image, tag, want string
+	}{
+		{"docker.io/library/nginx", "1.25", ""},
+		{"ghcr.io/nethserver/mail", "v1.2.3", ""},
+		{"ghcr.io/nethserver/mail", "1.25-alpine3.19", "alpine"},
+		{"ghcr.io/nethserver/mail", "1.25-alpine", "alpine"},
+		{"ghcr.io/nethserver/mail", "8.2-fpm-alpine", "fpm-alpine"},
+		{"ghcr.io/nethserver/mail", "alpine3.19-1.25", "alpine"},
+		{"ghcr.io/nethserver/mail", "20240501", ""},
+		{"ghcr.io/nethserver/mail", "latest", "latest"},
+		// the first matching rule wins, even when its expression doesn't match
+		{"docker.io/library/nextcloud", "28.0.4-apache", "apache"},
+		{"docker.io/library/nextcloud", "28.0.4", ""},
+		{"docker.io/library/postgres", "15-bookworm", "bookworm"},
+	}
+	for _, tt := range tests {
+		if got := c.Variant(tt.image, tt.tag); got != tt.want {
+			t.Errorf("Variant(%s, %s) = %q, want %q", tt.image, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestFilterVariant(t *testing.T) {
+	c := newTestClient(t, &config.Config{})
+	tags := []Tag{{Name: "15.4"}, {Name: "15.5-alpine"}, {Name: "16.0"}, {Name: "16.1-alpine3.19"}, {Name: "16.1-bookworm"}}
+	tests := []struct {
+		current string
+		want    []string
+	}{
+		{"15.4-alpine", []string{"15.5-alpine", "16.1-alpine3.19"}},
+		{"15.4", []string{"15.4", "16.0"}},
+		{"15-bookworm", []string{"16.1-bookworm"}},
+	}
+	for _, tt := range tests {
+		if got := tagNames(c.FilterVariant("docker.io/library/postgres", tt.current, tags)); !slices.Equal(got, tt.want) {
+			t.Errorf("FilterVariant(%s) = %v, want %v", tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestVariantRules(t *testing.T) {
+	tests := []struct {
+		pattern string
+		err     string
+	}{
+		{`-(\w+)$`, ""},
+		{`-(\w+)-(\w+)$`, "exactly one capture group"},
+		{`-\w+$`, "exactly one capture group"},
+		{`-(\w+$`, "missing closing )"},
+	}
+	for _, tt := range tests {
+		_, err := NewClient(context.Background(), &config.Config{Variants: []config.VariantConfig{{Image: "*", Pattern: tt.pattern}}})
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: got %v, want %q", tt.pattern, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Changes since the previous scan, see Dependency.Change
+const (
+	ChangeNewUpdate = "new update"
+	ChangeResolved  = "resolved"
+	ChangeNewError  = "new error"
+)
+
+// Changes returns the dependencies of current whose update or error is new
+// since previous, and those outdated or failing in previous that no longer
+// are, with their Change set. A reference of previous missing from current
+// is only resolved when covered reports that it was scanned again, nil covers
+// every reference.
+func Changes(previous, current []Dependency, covered func(Dependency) bool) []Dependency {
+	prev := make(map[string]Dependency, len(previous))
+	for _, p := range previous {
+		prev[changeKey(p)] = p
+	}
+
+	var changed []Dependency
+	for _, d := range current {
+		p, seen := prev[changeKey(d)]
+		delete(prev, changeKey(d))
+		switch {
+		case d.Error != "" && (!seen || p.Error == ""):
+			d.Change = ChangeNewError
+		case d.Error == "" && d.Outdated() && (!seen || !p.Outdated() || p.Latest != d.Latest):
+			d.Change = ChangeNewUpdate
+		case seen && d.Error == "" && !d.Outdated() && (p.Error != "" || p.Outdated()):
+			d.Change = ChangeResolved
+		default:
+			continue
+		}
+		changed = append(changed, d)
+	}
+	// references removed since, in previous order
+	for _, p := range previous {
+		if _, ok := prev[changeKey(p)]; ok && (p.Error != "" || p.Outdated()) && (covered == nil || covered(p)) {
+			p.Change = ChangeResolved
+			changed = append(changed, p)
+		}
+	}
+	return changed
+}
+
+// Merge returns current followed by the references of previous that were not
+// scanned again: those covered doesn't report, e.g. of repositories that
+// failed or were left out, and not found again in current
+func Merge(previous, current []Dependency, covered func(Dependency) bool) []Dependency {
+	seen := make(map[string]bool, len(current))
+	for _, d := range current {
+		seen[changeKey(d)] = true
+	}
+	merged := slices.Clone(current)
+	for _, p := range previous {
+		if !covered(p) && !seen[changeKey(p)] {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+func changeKey(d Dependency) string {
+	return d.Repository + "\x00" + d.Kind + "\x00" + d.ID + "\x00" + d.Image
+}
+
+// Load reads the dependencies of a report written in the json format. A
+// missing file yields no dependency.
+func Load(fileName string) ([]Dependency, error) {
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading report %s: %w", fileName, err)
+	}
+	deps, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report %s: %w", fileName, err)
+	}
+	return deps, nil
+}
+
+// Parse decodes the dependencies of a report in the json format
+func Parse(data []byte) ([]Dependency, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	return envelope.Results, nil
+}
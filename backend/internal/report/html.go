@@ -0,0 +1,49 @@
+package report
+
+import (
+	"html/template"
+	"io"
+)
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>ns8-updater report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.6em; text-align: left; }
+th { background: #f4f4f4; }
+tr.outdated td { background: #fff6e0; }
+tr.error td { background: #fde8e8; }
+code { font-size: 0.95em; }
+</style>
+</head>
+<body>
+<h1>Image updates</h1>
+<table>
+<thead>
+<tr><th>Repository</th><th>File</th><th>Image</th><th>Current</th><th>Latest</th><th>Status</th></tr>
+</thead>
+<tbody>
+{{- range .}}
+<tr class="{{if .Error}}error{{else if .Outdated}}outdated{{end}}">
+<td>{{.Repository}}</td>
+<td><code>{{.File}}</code></td>
+<td><code>{{.Image}}</code></td>
+<td><code>{{.Current}}</code></td>
+<td>{{if .Latest}}<code>{{.Latest}}</code>{{else}}-{{end}}</td>
+<td>{{.Status}}{{if .Error}}: {{.Error}}{{end}}{{if .Held}} {{.Held}}{{end}}{{with .EOLNote}}, {{.}}{{end}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// renderHTML writes a standalone HTML page
+func renderHTML(w io.Writer, deps []Dependency) error {
+	return htmlTemplate.Execute(w, deps)
+}
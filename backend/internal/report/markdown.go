@@ -0,0 +1,85 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// renderMarkdown writes a summary table suitable for posting to issues
+func renderMarkdown(w io.Writer, deps []Dependency) error {
+	outdated := 0
+	for _, d := range deps {
+		if d.Outdated() {
+			outdated++
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("## Image updates\n\n")
+	fmt.Fprintf(&b, "%d of %d images have updates available.\n\n", outdated, len(deps))
+	b.WriteString("| Repository | File | Image | Current | Latest | Status |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, d := range deps {
+		status := d.Status()
+		if d.Error != "" {
+			status += ": " + d.Error
+		}
+		if len(d.RiskFactors) > 0 {
+			status += fmt.Sprintf(", risk %d", d.Risk)
+		}
+		if d.Held != "" {
+			status += " " + d.Held
+		}
+		if d.ContentChanged {
+			status += ", content changed behind tag"
+		}
+		if len(d.Quarantined) > 0 {
+			status += ", skipped quarantined " + strings.Join(d.Quarantined, ", ")
+		}
+		if d.Change != "" {
+			status += ", " + d.Change + " since last scan"
+		}
+		if d.EOL != "" {
+			status += ", " + d.EOLNote()
+		}
+		if len(d.IncompatiblePlatforms) > 0 {
+			status += ", skipped incompatible platforms " + strings.Join(d.IncompatiblePlatforms, ", ")
+		}
+		if d.TagsTruncated {
+			status += ", tag listing truncated"
+		}
+		if d.Verification != "" {
+			status += ", verified with " + d.Verification
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | `%s` | `%s` | %s | %s |\n",
+			escapeMarkdown(d.ProjectName()),
+			d.Location(),
+			d.Image,
+			d.Current,
+			markdownCode(d.Latest),
+			escapeMarkdown(status),
+		)
+	}
+	if shared := SharedVariables(deps); len(shared) > 0 {
+		b.WriteString("\n### Shared variables\n\n")
+		for _, v := range shared {
+			fmt.Fprintf(&b, "- %s `%s`: `%s` affects %d images\n", escapeMarkdown(v.Repository), v.File, v.Name, len(v.Images))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return "`" + s + "`"
+}
+
+func escapeMarkdown(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,365 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Supported output formats
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatMarkdown = "md"
+	FormatSARIF    = "sarif"
+	FormatCSV      = "csv"
+	FormatHTML     = "html"
+)
+
+// Formats lists every format accepted by Render
+var Formats = []string{FormatText, FormatJSON, FormatMarkdown, FormatSARIF, FormatCSV, FormatHTML}
+
+// IsSupported reports whether format can be passed to Render
+func IsSupported(format string) bool {
+	return slices.Contains(Formats, format)
+}
+
+// Dependency is a single image reference found in a repository together with
+// the outcome of its update check. Its JSON encoding is part of the v1 API of
+// pkg/updater: fields may be added, never renamed or removed.
+type Dependency struct {
+	// Kind is empty for image references found in files and KindModule for
+	// the module image the repository publishes
+	Kind       string `json:"kind,omitempty"`
+	ID         string `json:"id"`
+	Repository string `json:"repository"`
+	// Groups are the configured groups of Repository
+	Groups []string `json:"groups,omitempty"`
+	// Topics are the GitHub topics of Repository
+	Topics []string `json:"topics,omitempty"`
+	// Project is the directory of File within Repository when it is not
+	// the root, for repositories hosting several modules
+	Project string `json:"project,omitempty"`
+	File    string `json:"file"`
+	Image   string `json:"image"`
+	Current string `json:"current"`
+	Latest  string `json:"latest,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Unsupported is set when the image is hosted on a registry the updater
+	// cannot query, Error holds the details
+	Unsupported bool `json:"unsupported,omitempty"`
+	// Digest and Published describe what a tag without comparable version,
+	// like latest, currently points to
+	Digest    string `json:"digest,omitempty"`
+	Published string `json:"published,omitempty"`
+	// LatestPublished, LatestDigest and LatestPlatforms describe Latest when
+	// the registry listing tells
+	LatestPublished string   `json:"latest_published,omitempty"`
+	LatestDigest    string   `json:"latest_digest,omitempty"`
+	LatestPlatforms []string `json:"latest_platforms,omitempty"`
+	// ContentChanged is set when the pinned digest differs from the one the
+	// tag points to now
+	ContentChanged bool `json:"content_changed,omitempty"`
+	// Signed tells whether Latest carries a signature or attestation, nil when
+	// it was not checked
+	Signed *bool `json:"signed,omitempty"`
+	// Verification describes how the signature of Latest was verified, e.g.
+	// "cosign key", empty when no verification is configured
+	Verification string `json:"verification,omitempty"`
+	// PendingSince is set on module images when the repository changed its
+	// pinned images after the last release, to the date of the oldest
+	// unreleased change
+	PendingSince string `json:"pending_since,omitempty"`
+	// Quarantined lists newer versions that were skipped because they are
+	// known to be bad
+	Quarantined []string `json:"quarantined,omitempty"`
+	// Unpinned is set when Current is latest or a placeholder, Latest is
+	// then the version to pin it to
+	Unpinned bool `json:"unpinned,omitempty"`
+	// EOL is the end of life date (YYYY-MM-DD) of the release cycle of
+	// Current, when known, EOLStatus is EOLReached once it passed or EOLSoon
+	// within the configured warning
+	EOL       string `json:"eol,omitempty"`
+	EOLStatus string `json:"eol_status,omitempty"`
+	// IncompatiblePlatforms lists newer versions that were skipped because
+	// they are not published for every required platform
+	IncompatiblePlatforms []string `json:"incompatible_platforms,omitempty"`
+	// TagsTruncated is set when the tag listing stopped at the page limit,
+	// so Latest may miss versions only listed on the later pages
+	TagsTruncated bool `json:"tags_truncated,omitempty"`
+	// Risk estimates from 0 to 100 how likely the update is to break things,
+	// RiskFactors explains the score
+	Risk        int      `json:"risk,omitempty"`
+	RiskFactors []string `json:"risk_factors,omitempty"`
+	// Partial is set when the repository scan ran out of budget or some of
+	// its files could not be read, so other dependencies may be missing
+	Partial bool `json:"partial,omitempty"`
+	// Archived is set on the single entry reported for an archived
+	// repository, which is excluded from the checks
+	Archived bool `json:"archived,omitempty"`
+	// Line and Column locate the image reference in File, Snippet is the
+	// line it is on
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+	// Diff is the unified diff the update would make, set on request
+	Diff string `json:"diff,omitempty"`
+	// Variables are the shell variables the image reference is built from,
+	// updating one updates every image sharing it
+	Variables []string `json:"variables,omitempty"`
+	// Change tells what changed since the previous scan, set on request:
+	// ChangeNewUpdate, ChangeResolved or ChangeNewError
+	Change string `json:"change,omitempty"`
+	// Held describes the configured hold of an outdated dependency whose
+	// update must not be applied, e.g. "until 2025-01-15: release freeze"
+	Held string `json:"held,omitempty"`
+}
+
+// KindModule marks the published module image of a repository
+const KindModule = "module"
+
+// End of life statuses, see Dependency.EOLStatus
+const (
+	EOLReached = "eol"
+	EOLSoon    = "eol_soon"
+)
+
+// EOLNote describes the end of life of Current, empty when unknown
+func (d Dependency) EOLNote() string {
+	switch {
+	case d.EOL == "":
+		return ""
+	case d.EOLStatus == EOLReached:
+		return "end of life since " + d.EOL
+	case d.EOLStatus == EOLSoon:
+		return "end of life soon, on " + d.EOL
+	default:
+		return "end of life " + d.EOL
+	}
+}
+
+// Outdated reports whether a newer tag than the current one was found, or
+// the content behind the current tag changed
+func (d Dependency) Outdated() bool {
+	if d.Kind == KindModule {
+		return false
+	}
+	return d.ContentChanged || (d.Latest != "" && d.Latest != d.Current)
+}
+
+// ProjectName returns Repository followed by Project when set, e.g.
+// org/ns8-apps/mail
+func (d Dependency) ProjectName() string {
+	if d.Project == "" {
+		return d.Repository
+	}
+	return d.Repository + "/" + d.Project
+}
+
+// Location returns File followed by the line of the reference when known
+func (d Dependency) Location() string {
+	if d.Line > 0 {
+		return fmt.Sprintf("%s:%d", d.File, d.Line)
+	}
+	return d.File
+}
+
+// SignedText returns yes or no for checked signatures, an empty string
+// otherwise
+func (d Dependency) SignedText() string {
+	switch {
+	case d.Signed == nil:
+		return ""
+	case *d.Signed:
+		return "yes"
+	default:
+		return "no"
+	}
+}
+
+// Status returns a short human readable state of the dependency
+func (d Dependency) Status() string {
+	switch {
+	case d.Archived:
+		return "archived"
+	case d.Unsupported:
+		return "unsupported"
+	case d.Error != "":
+		return "error"
+	case d.Kind == KindModule && d.PendingSince != "":
+		return "pending release"
+	case d.Kind == KindModule:
+		return "published"
+	case d.Outdated() && d.Held != "":
+		return "held"
+	case d.Outdated() && d.Unpinned:
+		return "unpinned"
+	case d.Outdated():
+		return "outdated"
+	default:
+		return "up to date"
+	}
+}
+
+// Render writes the dependencies to w in the requested format
+func Render(w io.Writer, format string, deps []Dependency) error {
+	switch format {
+	case FormatText:
+		return renderText(w, deps)
+	case FormatJSON:
+		return renderJSON(w, deps)
+	case FormatMarkdown:
+		return renderMarkdown(w, deps)
+	case FormatSARIF:
+		return renderSARIF(w, deps)
+	case FormatCSV:
+		return renderCSV(w, deps)
+	case FormatHTML:
+		return renderHTML(w, deps)
+	default:
+		return fmt.Errorf("unknown format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+func renderText(w io.Writer, deps []Dependency) error {
+	for _, d := range deps {
+		var err error
+		switch d.Status() {
+		case "error", "unsupported":
+			_, err = fmt.Fprintf(w, "%s %s %s:%s %s: %s\n", d.Repository, d.Location(), d.Image, d.Current, d.Status(), d.Error)
+		case "outdated":
+			if d.ContentChanged {
+				_, err = fmt.Fprintf(w, "%s %s %s:%s content changed behind tag, now %s published %s\n", d.Repository, d.Location(), d.Image, d.Current, d.Digest, d.Published)
+				break
+			}
+			signed := ""
+			if d.Signed != nil {
+				signed = " (signed: " + d.SignedText() + ")"
+			}
+			risk := ""
+			if len(d.RiskFactors) > 0 {
+				risk = fmt.Sprintf(" (risk %d: %s)", d.Risk, strings.Join(d.RiskFactors, ", "))
+			}
+			_, err = fmt.Fprintf(w, "%s %s %s:%s -> %s%s%s\n", d.Repository, d.Location(), d.Image, d.Current, d.Latest, signed, risk)
+			if err == nil && d.Diff != "" {
+				_, err = io.WriteString(w, d.Diff)
+			}
+		case "unpinned":
+			_, err = fmt.Fprintf(w, "%s %s %s:%s -> pin to %s\n", d.Repository, d.Location(), d.Image, d.Current, d.Latest)
+			if err == nil && d.Diff != "" {
+				_, err = io.WriteString(w, d.Diff)
+			}
+		case "held":
+			_, err = fmt.Fprintf(w, "%s %s %s:%s -> %s held %s\n", d.Repository, d.Location(), d.Image, d.Current, d.Latest, d.Held)
+		case "archived":
+			_, err = fmt.Fprintf(w, "%s archived, not checked\n", d.Repository)
+		case "pending release":
+			_, err = fmt.Fprintf(w, "%s module %s:%s published %s, pending release of changes since %s\n", d.Repository, d.Image, d.Latest, d.Published, d.PendingSince)
+		case "published":
+			_, err = fmt.Fprintf(w, "%s module %s:%s published %s\n", d.Repository, d.Image, d.Latest, d.Published)
+		default:
+			_, err = fmt.Fprintf(w, "%s %s %s:%s up to date\n", d.Repository, d.Location(), d.Image, d.Current)
+		}
+		if err != nil {
+			return err
+		}
+		if len(d.Quarantined) > 0 {
+			if _, err := fmt.Fprintf(w, "  skipped quarantined: %s\n", strings.Join(d.Quarantined, ", ")); err != nil {
+				return err
+			}
+		}
+		if d.Change != "" {
+			if _, err := fmt.Fprintf(w, "  since last scan: %s\n", d.Change); err != nil {
+				return err
+			}
+		}
+		if d.EOL != "" {
+			if _, err := fmt.Fprintf(w, "  %s:%s %s\n", d.Image, d.Current, d.EOLNote()); err != nil {
+				return err
+			}
+		}
+		if len(d.IncompatiblePlatforms) > 0 {
+			if _, err := fmt.Fprintf(w, "  skipped incompatible platforms: %s\n", strings.Join(d.IncompatiblePlatforms, ", ")); err != nil {
+				return err
+			}
+		}
+		if d.TagsTruncated {
+			if _, err := fmt.Fprintln(w, "  tag listing truncated at the page limit, newer tags may be missing"); err != nil {
+				return err
+			}
+		}
+	}
+	for _, v := range SharedVariables(deps) {
+		if _, err := fmt.Fprintf(w, "%s %s variable %s, %d images affected: %s\n", v.Repository, v.File, v.Name, len(v.Images), strings.Join(v.Images, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Envelope wraps the dependencies in the JSON output so that consumers can
+// pin against SchemaVersion, Schema describes its shape
+type Envelope struct {
+	SchemaVersion int          `json:"schema_version"`
+	GeneratedAt   time.Time    `json:"generated_at"`
+	Results       []Dependency `json:"results"`
+}
+
+// SharedVariable is a shell variable several images of a file are built from
+type SharedVariable struct {
+	Repository string
+	File       string
+	Name       string
+	Images     []string
+}
+
+// SharedVariables returns the variables used by more than one image of the
+// same file, in order of first use
+func SharedVariables(deps []Dependency) []SharedVariable {
+	var shared []SharedVariable
+	index := map[string]int{}
+	for _, d := range deps {
+		for _, v := range d.Variables {
+			key := d.Repository + "\x00" + d.File + "\x00" + v
+			i, ok := index[key]
+			if !ok {
+				i = len(shared)
+				index[key] = i
+				shared = append(shared, SharedVariable{Repository: d.Repository, File: d.File, Name: v})
+			}
+			shared[i].Images = append(shared[i].Images, d.Image)
+		}
+	}
+	return slices.DeleteFunc(shared, func(s SharedVariable) bool { return len(s.Images) < 2 })
+}
+
+func renderJSON(w io.Writer, deps []Dependency) error {
+	if deps == nil {
+		deps = []Dependency{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Envelope{
+		SchemaVersion: SchemaVersion,
+		GeneratedAt:   time.Now().UTC().Truncate(time.Second),
+		Results:       deps,
+	})
+}
+
+func renderCSV(w io.Writer, deps []Dependency) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "repository", "file", "image", "current", "latest", "status", "signed", "error"}); err != nil {
+		return err
+	}
+	for _, d := range deps {
+		if err := cw.Write([]string{d.ID, d.Repository, d.File, d.Image, d.Current, d.Latest, d.Status(), d.SignedText(), d.Error}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,138 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	sarifSchema   = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion  = "2.1.0"
+	toolName      = "ns8-updater"
+	toolInfoURI   = "https://github.com/geniusdynamics/updater"
+	ruleOutdated  = "outdated-image"
+	ruleLookupErr = "image-lookup-failed"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// renderSARIF writes a SARIF 2.1.0 log for GitHub code scanning upload. Only
+// outdated images and failed lookups are reported as results.
+func renderSARIF(w io.Writer, deps []Dependency) error {
+	results := []sarifResult{}
+	for _, d := range deps {
+		var r sarifResult
+		switch d.Status() {
+		case "outdated":
+			r = sarifResult{
+				RuleID:  ruleOutdated,
+				Level:   "warning",
+				Message: sarifMessage{Text: fmt.Sprintf("%s:%s can be updated to %s", d.Image, d.Current, d.Latest)},
+			}
+			if d.ContentChanged {
+				r.Message.Text = fmt.Sprintf("%s:%s now points to %s", d.Image, d.Current, d.Digest)
+			}
+		case "unpinned":
+			r = sarifResult{
+				RuleID:  ruleOutdated,
+				Level:   "warning",
+				Message: sarifMessage{Text: fmt.Sprintf("%s:%s can be pinned to %s", d.Image, d.Current, d.Latest)},
+			}
+		case "error", "unsupported":
+			r = sarifResult{
+				RuleID:  ruleLookupErr,
+				Level:   "note",
+				Message: sarifMessage{Text: fmt.Sprintf("could not check %s:%s for updates: %s", d.Image, d.Current, d.Error)},
+			}
+		default:
+			continue
+		}
+		r.Locations = []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: d.File},
+			},
+		}}
+		if d.Line > 0 {
+			r.Locations[0].PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+		}
+		if d.ID != "" {
+			r.PartialFingerprints = map[string]string{"dependencyId": d.ID}
+		}
+		results = append(results, r)
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           toolName,
+				InformationURI: toolInfoURI,
+				Rules: []sarifRule{
+					{ID: ruleOutdated, ShortDescription: sarifMessage{Text: "Container image has a newer version available"}},
+					{ID: ruleLookupErr, ShortDescription: sarifMessage{Text: "Container image could not be checked for updates"}},
+				},
+			}},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
@@ -0,0 +1,68 @@
+package report
+
+// SchemaVersion is the version of the JSON output, it is increased on changes
+// that are not backward compatible
+const SchemaVersion = 1
+
+// Schema is the JSON Schema of the json format
+const Schema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/geniusdynamics/updater/schema/v1.json",
+  "title": "updater report",
+  "type": "object",
+  "required": ["schema_version", "generated_at", "results"],
+  "properties": {
+    "schema_version": {"const": 1},
+    "generated_at": {"type": "string", "format": "date-time"},
+    "results": {
+      "type": "array",
+      "items": {"$ref": "#/$defs/dependency"}
+    }
+  },
+  "$defs": {
+    "dependency": {
+      "type": "object",
+      "required": ["id", "repository", "file", "image", "current"],
+      "properties": {
+        "kind": {"enum": ["module"]},
+        "id": {"type": "string"},
+        "repository": {"type": "string"},
+        "groups": {"type": "array", "items": {"type": "string"}},
+        "topics": {"type": "array", "items": {"type": "string"}},
+        "project": {"type": "string"},
+        "file": {"type": "string"},
+        "image": {"type": "string"},
+        "current": {"type": "string"},
+        "latest": {"type": "string"},
+        "error": {"type": "string"},
+        "unsupported": {"type": "boolean"},
+        "digest": {"type": "string"},
+        "published": {"type": "string"},
+        "latest_published": {"type": "string"},
+        "latest_digest": {"type": "string"},
+        "latest_platforms": {"type": "array", "items": {"type": "string"}},
+        "content_changed": {"type": "boolean"},
+        "signed": {"type": "boolean"},
+        "verification": {"type": "string"},
+        "pending_since": {"type": "string"},
+        "quarantined": {"type": "array", "items": {"type": "string"}},
+        "unpinned": {"type": "boolean"},
+        "eol": {"type": "string", "format": "date"},
+        "eol_status": {"enum": ["eol", "eol_soon"]},
+        "incompatible_platforms": {"type": "array", "items": {"type": "string"}},
+        "tags_truncated": {"type": "boolean"},
+        "risk": {"type": "integer", "minimum": 0, "maximum": 100},
+        "risk_factors": {"type": "array", "items": {"type": "string"}},
+        "partial": {"type": "boolean"},
+        "archived": {"type": "boolean"},
+        "held": {"type": "string"},
+        "change": {"enum": ["new update", "resolved", "new error"]},
+        "line": {"type": "integer", "minimum": 1},
+        "column": {"type": "integer", "minimum": 1},
+        "snippet": {"type": "string"},
+        "diff": {"type": "string"},
+        "variables": {"type": "array", "items": {"type": "string"}}
+      }
+    }
+  }
+}`
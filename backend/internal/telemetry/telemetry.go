@@ -0,0 +1,76 @@
+// Package telemetry sends anonymous usage statistics to an opt-in endpoint.
+// Only counts are sent, never repository, file or image names.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+	"github.com/geniusdynamics/updater/backend/internal/report"
+)
+
+// Event summarizes one run
+type Event struct {
+	Version string `json:"version"`
+	Command string `json:"command"`
+	// Repositories is the number of checked repositories
+	Repositories int `json:"repositories"`
+	// Statuses counts the dependencies by status, e.g. outdated
+	Statuses map[string]int `json:"statuses"`
+	// Registries counts the dependencies by public registry host, the
+	// others are counted as other
+	Registries map[string]int `json:"registries"`
+}
+
+// publicRegistries are the registry hosts reported by name
+var publicRegistries = []string{"docker.io", "ghcr.io", "quay.io", "gcr.io", "registry.k8s.io", "mcr.microsoft.com", "public.ecr.aws"}
+
+// NewEvent counts deps of a run of command
+func NewEvent(command string, deps []report.Dependency) Event {
+	e := Event{
+		Version:    config.Version,
+		Command:    command,
+		Statuses:   map[string]int{},
+		Registries: map[string]int{},
+	}
+	repos := map[string]bool{}
+	for _, d := range deps {
+		repos[d.Repository] = true
+		e.Statuses[d.Status()]++
+		host, _, _ := strings.Cut(d.Image, "/")
+		if !slices.Contains(publicRegistries, host) {
+			host = "other"
+		}
+		e.Registries[host]++
+	}
+	e.Repositories = len(repos)
+	return e
+}
+
+// Send posts e as JSON to url
+func Send(ctx context.Context, client *http.Client, url string, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending telemetry: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error sending telemetry: %s", resp.Status)
+	}
+	return nil
+}
@@ -1,57 +1,360 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 
-	"github.com/geniusdynamics/updater/backend/internal/config"
 	"github.com/geniusdynamics/updater/backend/internal/files"
-	"github.com/geniusdynamics/updater/backend/internal/git"
-	"github.com/geniusdynamics/updater/backend/internal/images"
+	"github.com/geniusdynamics/updater/backend/internal/report"
+	"github.com/geniusdynamics/updater/backend/internal/telemetry"
+	"github.com/geniusdynamics/updater/backend/pkg/updater"
+	"github.com/google/go-github/v81/github"
 )
 
+// commands maps the sub-commands to their entry point, without a command
+// the repositories are scanned
+var commands = map[string]func(args []string){
+	"annotate":       runAnnotate,
+	"config":         runConfig,
+	"doctor":         runDoctor,
+	"export-catalog": runExportCatalog,
+	"hook":           runHook,
+	"report":         runReport,
+	"scan":           runScan,
+	"sync":           runSync,
+	"tags":           runTags,
+}
+
 func main() {
+	args := selectProfile(os.Args[1:])
+	if len(args) > 0 {
+		if run, ok := commands[args[0]]; ok {
+			run(args[1:])
+			return
+		}
+	}
+	runScan(args)
+}
+
+// selectProfile handles a leading -profile name, or --profile=name, which
+// selects a profile of the config file for any command, and returns the
+// remaining arguments
+func selectProfile(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+	if !strings.HasPrefix(args[0], "-") || name != "profile" {
+		return args
+	}
+	if !hasValue {
+		if len(args) < 2 {
+			log.Fatal("-profile needs a profile name")
+		}
+		value, args = args[1], args[1:]
+	}
+	if err := os.Setenv("NS8_UPDATER_PROFILE", value); err != nil {
+		log.Fatal(err)
+	}
+	return args[1:]
+}
+
+// setup loads the environment and configuration and returns the updater
+// with the context of the run
+func setup() (context.Context, <-chan struct{}, *updater.Config, *updater.Updater) {
 	err := files.LoadEnv(".env")
 	if err != nil {
 		log.Println(err)
 	}
-	cfg := config.NewConfig()
+	ctx, draining := handleSignals()
 
-	githubClient := git.NewGitHubClient(cfg)
-	if _, err := githubClient.GetRepositories(); err != nil {
+	cfg, err := updater.LoadConfig()
+	if err != nil {
 		log.Fatal(err)
 	}
-	repos, err := githubClient.SearchRepositories("ns8-")
+	u, err := updater.New(ctx, cfg)
 	if err != nil {
-		log.Fatalf("%s", err)
+		log.Fatal(err)
+	}
+	u.Logger = log.Default()
+	return ctx, draining, cfg, u
+}
+
+func runScan(args []string) {
+	flags := flag.NewFlagSet("scan", flag.ExitOnError)
+	format := flags.String("format", report.FormatText, "output format: "+strings.Join(report.Formats, ", "))
+	remote := flags.Bool("remote", false, "read scanned files through the GitHub API instead of cloning")
+	exportBundle := flags.String("export-bundle", "", "write the resolved targets and digests to this file for air-gapped sites")
+	importBundle := flags.String("import-bundle", "", "resolve targets from this exported bundle instead of the registries")
+	flags.StringVar(importBundle, "catalog", "", "alias of -import-bundle")
+	offline := flags.Bool("offline", false, "scan the local clones as they are and resolve targets from -catalog only, without network access")
+	group := flags.String("group", "", "only check repositories of this configured group")
+	topic := flags.String("topic", "", "only check repositories tagged with this GitHub topic, e.g. ns8")
+	project := flags.String("project", "", "only report the images of this project, a repository or a subdirectory of it, e.g. org/ns8-apps/mail")
+	verbose := flags.Bool("verbose", false, "log the scanned files in which no image was found")
+	schema := flags.Bool("schema", false, "print the JSON schema of the json format and exit")
+	noProgress := flags.Bool("no-progress", false, "don't display the progress of the checks, it is only displayed when the output is a terminal")
+	diff := flags.Bool("diff", false, "show the diff each update would make, without applying it")
+	sinceLast := flags.Bool("since-last", false, "only report the updates, resolved updates and errors that are new since the previous -since-last scan")
+	pin := flags.Bool("pin", false, "only report images referenced by latest or a placeholder, with the version to pin them to")
+	_ = flags.Parse(args)
+	if *schema {
+		fmt.Println(report.Schema)
+		return
+	}
+	if !report.IsSupported(*format) {
+		log.Fatalf("unknown format %q, expected one of: %s", *format, strings.Join(report.Formats, ", "))
 	}
-	fileNames := map[string]bool{
-		"build-images.sh": true,
+	if *offline && *importBundle == "" {
+		log.Fatal("-offline needs a -catalog to resolve targets from")
+	}
+	if *sinceLast && *pin {
+		// pinning proposals aren't comparable with the saved updates
+		log.Fatal("-since-last can't be combined with -pin")
 	}
-	for i := range 4 {
-		repo := repos.Repositories[i]
 
-		dir, err := githubClient.CloneRepository(*repo.CloneURL)
-		if err != nil {
-			log.Fatalf("%s \n", err)
+	ctx, draining, cfg, u := setup()
+	u.Offline = *offline
+	u.Diffs = *diff
+	u.Pin = *pin
+	var err error
+	if *importBundle != "" {
+		if u.Bundle, err = updater.ReadBundle(*importBundle); err != nil {
+			log.Fatal(err)
 		}
-		fmt.Printf("Github Repo: %s \n", dir)
-		dockerImages, err := files.FindDockerImages(dir, fileNames)
-		if err != nil {
-			log.Fatalf("An error occurred: %s \n", err)
+	}
+
+	// the saved state also tells what unpinned tags pointed to last time
+	var previous []updater.Dependency
+	if *sinceLast || cfg.CompareDigests {
+		if previous, err = report.Load(cfg.ScanState); err != nil {
+			log.Fatal(err)
 		}
-		for _, image := range dockerImages {
-			fmt.Printf("Image: %s, %s, %s \n", image.Registry, image.Repo, image.Tag)
-			tags, err := images.GetImageUpdates(image.Registry, image.Repo)
-			if err != nil {
-				log.Printf("Error getting updates for %s: %s", image.Repo, err)
-				continue
-			}
-			if len(tags) > 0 {
-				fmt.Printf("tag: %s found\n", tags[0])
-			} else {
-				fmt.Printf("tags: %s\n", image.Tag)
+		u.RememberDigests(previous)
+	}
+
+	stopProgress := func() {}
+	if !*noProgress && isTerminal(os.Stdout) && isTerminal(os.Stderr) {
+		stopProgress = showProgress(u)
+	}
+	dependencies, checked, failed := checkRepositories(ctx, draining, cfg, u, *group, *topic, *remote)
+	stopProgress()
+	if *pin {
+		dependencies = slices.DeleteFunc(dependencies, func(d updater.Dependency) bool { return !d.Unpinned })
+	}
+	if *project != "" {
+		dependencies = slices.DeleteFunc(dependencies, func(d updater.Dependency) bool { return d.ProjectName() != *project })
+	}
+	scanned := dependencies
+	// the references of failed, partially scanned or filtered out
+	// repositories and projects keep their saved state
+	covered := func(d updater.Dependency) bool {
+		return checked[d.Repository] && (*project == "" || d.ProjectName() == *project)
+	}
+	var state []updater.Dependency
+	if *sinceLast {
+		dependencies = report.Changes(previous, dependencies, covered)
+		state = report.Merge(previous, scanned, covered)
+	}
+	if *verbose {
+		for _, d := range u.Diagnostics {
+			if d.Images == 0 {
+				log.Printf("No image found in %s/%s (skipped: %s, patterns: %s)", d.Repository, d.File, orNone(d.Skipped), strings.Join(d.Patterns, " "))
 			}
 		}
 	}
+	if err := report.Render(os.Stdout, *format, dependencies); err != nil {
+		log.Fatal(err)
+	}
+	if *sinceLast {
+		if err := writeState(cfg.ScanState, state); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *exportBundle != "" {
+		if err := writeBundle(ctx, u, *exportBundle, scanned); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.TelemetryURL != "" && !*offline {
+		if err := telemetry.Send(ctx, cfg.HttpClient, cfg.TelemetryURL, telemetry.NewEvent("scan", scanned)); err != nil {
+			log.Println(err)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkRepositories checks the repositories of group tagged with topic, all
+// of them when both are empty, until draining is closed. Repositories are
+// checked concurrently, a failing one doesn't stop the others: the failures
+// are logged in the final summary and counted in the returned number. The
+// full names of the repositories checked completely are returned as well.
+func checkRepositories(ctx context.Context, draining <-chan struct{}, cfg *updater.Config, u *updater.Updater, group, topic string, remote bool) ([]updater.Dependency, map[string]bool, int) {
+	var repos []*github.Repository
+	var err error
+	if u.Offline {
+		repos, err = u.LocalRepositories("ns8-")
+	} else {
+		repos, err = u.Repositories(ctx, "ns8-")
+	}
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	var selected []*github.Repository
+	for _, repo := range repos {
+		if group != "" && !cfg.InGroup(repo.GetName(), group) {
+			continue
+		}
+		if topic != "" && !slices.Contains(repo.Topics, topic) {
+			continue
+		}
+		log.Printf("Found repository: %s \n", repo.GetName())
+		selected = append(selected, repo)
+	}
+
+	results := make([]repoResult, len(selected))
+	sem := make(chan struct{}, max(1, cfg.RepositoryConcurrency))
+	var wg sync.WaitGroup
+repoLoop:
+	for i, repo := range selected {
+		select {
+		case <-draining:
+			log.Printf("Stopped before %s", repo.GetFullName())
+			break repoLoop
+		default:
+		}
+		select {
+		case <-draining:
+			log.Printf("Stopped before %s", repo.GetFullName())
+			break repoLoop
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = checkRepository(ctx, u, repo, remote)
+		}()
+	}
+	wg.Wait()
+
+	var dependencies []updater.Dependency
+	summary := map[string]int{}
+	checked := map[string]bool{}
+	var failures []string
+	for i, r := range results {
+		name := selected[i].GetFullName()
+		switch {
+		case !r.started:
+			summary["skipped"]++
+		case errors.Is(r.err, updater.ErrScanBudgetExceeded), errors.Is(r.err, updater.ErrUnreadableFiles):
+			log.Printf("Partial scan of %s: %s \n", name, r.err)
+			summary["partial"]++
+		case r.err != nil && ctx.Err() != nil:
+			log.Printf("Aborted %s: %s \n", name, r.err)
+			summary["skipped"]++
+		case r.err != nil:
+			log.Printf("An error occurred checking %s: %s \n", name, r.err)
+			failures = append(failures, name+": "+r.err.Error())
+			summary["failed"]++
+		default:
+			summary["checked"]++
+			checked[name] = true
+		}
+		dependencies = append(dependencies, r.deps...)
+	}
+	log.Printf("Repositories: %d checked, %d partial, %d skipped, %d failed", summary["checked"], summary["partial"], summary["skipped"], summary["failed"])
+	for _, f := range failures {
+		log.Printf("  failed %s", f)
+	}
+	return dependencies, checked, len(failures)
+}
+
+// repoResult is the outcome of checking one repository, started is false
+// for repositories left out after an interruption
+type repoResult struct {
+	deps    []updater.Dependency
+	err     error
+	started bool
+}
+
+// checkRepository checks repo, turning a panic into an error so that the
+// other repositories are still checked
+func checkRepository(ctx context.Context, u *updater.Updater, repo *github.Repository, remote bool) (r repoResult) {
+	r.started = true
+	defer func() {
+		if p := recover(); p != nil {
+			r.err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	r.deps, r.err = u.Check(ctx, repo, remote)
+	return r
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "no"
+	}
+	return s
+}
+
+// writeState saves deps for the next -since-last scan
+func writeState(fileName string, deps []updater.Dependency) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("error saving scan state: %w", err)
+	}
+	if err := report.Render(f, report.FormatJSON, deps); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeBundle exports the targets of deps to fileName
+func writeBundle(ctx context.Context, u *updater.Updater, fileName string, deps []updater.Dependency) error {
+	bundle, err := u.ExportBundle(ctx, deps)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("error creating bundle: %w", err)
+	}
+	if err := bundle.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// handleSignals returns a context cancelled on the second SIGINT or SIGTERM
+// and a channel closed on the first one. The first signal lets the repository
+// in flight finish and the report of what was checked be written, the second
+// aborts the work in flight.
+func handleSignals() (context.Context, <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	draining := make(chan struct{})
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-sigs
+		log.Println("Finishing the current repository, interrupt again to abort")
+		close(draining)
+		<-sigs
+		cancel()
+	}()
+	return ctx, draining
 }
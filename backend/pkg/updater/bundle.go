@@ -0,0 +1,100 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Bundle carries the target versions resolved on a connected machine into an
+// air-gapped site, where they replace the registry lookups
+type Bundle struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Images      []BundleImage `json:"images"`
+}
+
+// BundleImage is the target of an image pinned to Current
+type BundleImage struct {
+	Image   string `json:"image"`
+	Current string `json:"current"`
+	Target  string `json:"target"`
+	// Digest is what Target pointed to when the bundle was exported
+	Digest       string   `json:"digest,omitempty"`
+	Repositories []string `json:"repositories"`
+}
+
+// ExportBundle collects the targets of the outdated dependencies together
+// with their digests, one entry per image and current tag
+func (u *Updater) ExportBundle(ctx context.Context, deps []Dependency) (*Bundle, error) {
+	b := &Bundle{GeneratedAt: time.Now().UTC()}
+	index := map[string]int{}
+
+	for _, d := range deps {
+		if !d.Outdated() || d.Latest == "" {
+			continue
+		}
+		key := d.Image + ":" + d.Current
+		if i, ok := index[key]; ok {
+			if !slices.Contains(b.Images[i].Repositories, d.Repository) {
+				b.Images[i].Repositories = append(b.Images[i].Repositories, d.Repository)
+			}
+			continue
+		}
+
+		registry, repo := splitImage(d.Image)
+		digest, err := u.images.Digest(ctx, registry, repo, d.Latest)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %s:%s: %w", d.Image, d.Latest, err)
+		}
+		index[key] = len(b.Images)
+		b.Images = append(b.Images, BundleImage{
+			Image:        d.Image,
+			Current:      d.Current,
+			Target:       d.Latest,
+			Digest:       digest,
+			Repositories: []string{d.Repository},
+		})
+	}
+	return b, nil
+}
+
+// Write encodes the bundle as indented JSON
+func (b *Bundle) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// ReadBundle loads a bundle written by Bundle.Write
+func ReadBundle(fileName string) (*Bundle, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundle: %w", err)
+	}
+	b := &Bundle{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("error parsing bundle %s: %w", fileName, err)
+	}
+	return b, nil
+}
+
+// Lookup returns the target of image pinned to current
+func (b *Bundle) Lookup(image, current string) (BundleImage, bool) {
+	for _, entry := range b.Images {
+		if entry.Image == image && entry.Current == current {
+			return entry, true
+		}
+	}
+	return BundleImage{}, false
+}
+
+// splitImage splits an image name into its registry and repository
+func splitImage(name string) (string, string) {
+	registry, repo, _ := strings.Cut(name, "/")
+	return registry, repo
+}
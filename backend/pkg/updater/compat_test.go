@@ -0,0 +1,219 @@
+package updater
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/report"
+)
+
+// update rewrites the golden files, only after checking that the change of
+// the JSON encoding is backward compatible
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// fullDependency sets every field, so that a new field shows up in the golden
+// file and must be declared in the schema
+func fullDependency() Dependency {
+	signed := true
+	return Dependency{
+		Kind:                  report.KindModule,
+		ID:                    "0123456789abcdef",
+		Repository:            "geniusdynamics/ns8-example",
+		Groups:                []string{"collaboration"},
+		Topics:                []string{"ns8"},
+		Project:               "mail",
+		File:                  "mail/build-images.sh",
+		Image:                 "docker.io/library/postgres",
+		Current:               "15.4",
+		Latest:                "15.6",
+		Error:                 "lookup failed",
+		Unsupported:           true,
+		Digest:                "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		Published:             "2024-01-02T03:04:05Z",
+		LatestPublished:       "2024-02-03T04:05:06Z",
+		LatestDigest:          "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		LatestPlatforms:       []string{"linux/amd64", "linux/arm64"},
+		ContentChanged:        true,
+		Signed:                &signed,
+		Verification:          "cosign key",
+		PendingSince:          "2024-03-04",
+		Quarantined:           []string{"15.5 (data loss)"},
+		Unpinned:              true,
+		EOL:                   "2027-11-11",
+		EOLStatus:             report.EOLSoon,
+		IncompatiblePlatforms: []string{"15.7"},
+		TagsTruncated:         true,
+		Risk:                  40,
+		RiskFactors:           []string{"minor update"},
+		Partial:               true,
+		Archived:              true,
+		Line:                  3,
+		Column:                8,
+		Snippet:               "image=docker.io/library/postgres:15.4",
+		Diff:                  "-postgres:15.4\n+postgres:15.6\n",
+		Variables:             []string{"postgres_version"},
+		Change:                report.ChangeNewUpdate,
+		Held:                  "until 2025-01-15: release freeze",
+	}
+}
+
+func fullEnvelope() Envelope {
+	return Envelope{
+		SchemaVersion: report.SchemaVersion,
+		GeneratedAt:   time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Results:       []Dependency{fullDependency()},
+	}
+}
+
+func TestFullDependencySetsEveryField(t *testing.T) {
+	v := reflect.ValueOf(fullDependency())
+	for i := range v.NumField() {
+		if v.Field(i).IsZero() {
+			t.Errorf("fullDependency doesn't set %s", v.Type().Field(i).Name)
+		}
+	}
+}
+
+func TestDependencyJSON(t *testing.T) {
+	checkGolden(t, "dependency.json", fullDependency())
+}
+
+func TestEnvelopeJSON(t *testing.T) {
+	checkGolden(t, "envelope.json", fullEnvelope())
+}
+
+// checkGolden compares the indented JSON encoding of v with testdata/name
+func checkGolden(t *testing.T, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("the JSON encoding changed, which breaks the v1 API unless it only adds fields\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEnvelopeMatchesSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(report.Schema), &schema); err != nil {
+		t.Fatalf("invalid schema: %s", err)
+	}
+	data, err := json.Marshal(fullEnvelope())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range validate(schema, schema, doc, "$") {
+		t.Error(err)
+	}
+}
+
+// validate checks value against the subset of JSON Schema used by
+// report.Schema. Properties the schema doesn't declare are reported too, so
+// that fields aren't added to Dependency without documenting them.
+func validate(root, schema map[string]any, value any, at string) []error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def := root
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			def, _ = def[key].(map[string]any)
+		}
+		if def == nil {
+			return []error{fmt.Errorf("%s: unresolved $ref %s", at, ref)}
+		}
+		return validate(root, def, value, at)
+	}
+
+	var errs []error
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		errs = append(errs, fmt.Errorf("%s: %v is not the constant %v", at, value, c))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		errs = append(errs, fmt.Errorf("%s: %v is not one of %v", at, value, enum))
+	}
+	if typ, ok := schema["type"].(string); ok && !hasType(value, typ) {
+		return append(errs, fmt.Errorf("%s: %v is not of type %s", at, value, typ))
+	}
+	if n, ok := value.(float64); ok {
+		if minimum, ok := schema["minimum"].(float64); ok && n < minimum {
+			errs = append(errs, fmt.Errorf("%s: %v is below %v", at, n, minimum))
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && n > maximum {
+			errs = append(errs, fmt.Errorf("%s: %v is above %v", at, n, maximum))
+		}
+	}
+	if s, ok := value.(string); ok {
+		switch schema["format"] {
+		case "date":
+			if _, err := time.Parse(time.DateOnly, s); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a date", at, s))
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a date-time", at, s))
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range value.([]any) {
+			errs = append(errs, validate(root, items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	}
+	if object, ok := value.(map[string]any); ok {
+		required, _ := schema["required"].([]any)
+		for _, key := range required {
+			if _, ok := object[key.(string)]; !ok {
+				errs = append(errs, fmt.Errorf("%s: missing required %s", at, key))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for key, v := range object {
+			property, ok := properties[key].(map[string]any)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: %s is not declared in the schema", at, key))
+				continue
+			}
+			errs = append(errs, validate(root, property, v, at+"."+key)...)
+		}
+	}
+	return errs
+}
+
+func hasType(value any, typ string) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == float64(int64(v)))
+	case []any:
+		return typ == "array"
+	case map[string]any:
+		return typ == "object"
+	}
+	return typ == "null"
+}
@@ -0,0 +1,19 @@
+package updater
+
+import (
+	"context"
+)
+
+// CheckContent checks the images referenced in data, the content of a file
+// named fileName that doesn't need to exist locally, e.g. taken from a pull
+// request diff. The dependencies carry no repository.
+func (u *Updater) CheckContent(ctx context.Context, fileName string, data []byte) ([]Dependency, error) {
+	var dependencies []Dependency
+	for _, image := range u.ScanContent(fileName, data) {
+		if err := ctx.Err(); err != nil {
+			return dependencies, err
+		}
+		dependencies = append(dependencies, u.CheckImage(ctx, "", image))
+	}
+	return dependencies, nil
+}
@@ -0,0 +1,75 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v81/github"
+)
+
+// UpdateDiff returns the unified diff updating dep to its latest tag in
+// content, the file dep was found in. Images built from a variable are
+// updated where the variable is assigned. It returns an empty string when dep
+// is not outdated or the tag can't be located.
+func UpdateDiff(dep Dependency, content []byte) string {
+	if !dep.Outdated() || dep.Latest == "" || dep.ContentChanged || dep.Line <= 0 {
+		return ""
+	}
+	lines := strings.Split(string(content), "\n")
+	n, old, updated := -1, "", ""
+	for _, v := range dep.Variables {
+		assignment := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(v) + `=`)
+		for i, line := range lines {
+			if assignment.MatchString(line) && strings.Contains(line, dep.Current) {
+				n, old, updated = i, line, strings.Replace(line, dep.Current, dep.Latest, 1)
+				break
+			}
+		}
+	}
+	if n < 0 && dep.Line <= len(lines) {
+		line := lines[dep.Line-1]
+		start := min(max(dep.Column-1, 0), len(line))
+		if i := strings.Index(line[start:], ":"+dep.Current); i >= 0 {
+			i += start
+			n, old = dep.Line-1, line
+			updated = line[:i] + ":" + dep.Latest + line[i+1+len(dep.Current):]
+		}
+	}
+	if n < 0 {
+		return ""
+	}
+	return fmt.Sprintf("--- a/%s\n+++ b/%s\n@@ -%d +%d @@\n-%s\n+%s\n", dep.File, dep.File, n+1, n+1, old, updated)
+}
+
+// addDiffs fills in the diff of the outdated dependencies of repo, reading
+// their files from the clone or, in remote mode, through the GitHub API
+func (u *Updater) addDiffs(ctx context.Context, repo *github.Repository, remote bool, deps []Dependency) error {
+	client, err := u.clientFor(repo)
+	if err != nil {
+		return err
+	}
+	contents := map[string][]byte{}
+	for i, d := range deps {
+		if !d.Outdated() || d.File == "" {
+			continue
+		}
+		content, ok := contents[d.File]
+		if !ok {
+			if remote {
+				content, err = client.ReadFile(ctx, repo, d.File)
+			} else {
+				content, err = os.ReadFile(filepath.Join(client.ClonePath(repo.GetCloneURL()), filepath.FromSlash(d.File)))
+			}
+			if err != nil {
+				return err
+			}
+			contents[d.File] = content
+		}
+		deps[i].Diff = UpdateDiff(d, content)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+	"github.com/geniusdynamics/updater/backend/internal/files"
+)
+
+const (
+	oldDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	newDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// latestRegistry serves team/app:latest pointing to newDigest
+func latestRegistry(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/team/app/manifests/latest":
+			w.Header().Set("Docker-Content-Digest", newDigest)
+		case "/v2/team/app/manifests/" + newDigest:
+			w.Write([]byte(`{"annotations":{"org.opencontainers.image.created":"2024-05-06T07:08:09Z"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestCheckDigest(t *testing.T) {
+	host := latestRegistry(t)
+	u, err := New(context.Background(), &Config{
+		Registries:     []config.RegistryConfig{{Host: host, PlainHTTP: true}},
+		CompareDigests: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpinned := files.DockerImage{Registry: host, Repo: "team/app", Tag: "latest", File: "build-images.sh", Key: "image"}
+	pinned := unpinned
+	pinned.Digest = oldDigest
+
+	tests := []struct {
+		name     string
+		image    files.DockerImage
+		previous []Dependency
+		changed  bool
+	}{
+		{name: "unpinned latest without previous scan", image: unpinned},
+		{name: "unpinned latest unchanged", image: unpinned, previous: []Dependency{{ID: unpinned.ID("r"), Current: "latest", Digest: newDigest}}},
+		{name: "unpinned latest moved", image: unpinned, previous: []Dependency{{ID: unpinned.ID("r"), Current: "latest", Digest: oldDigest}}, changed: true},
+		{name: "previous scan of another tag", image: unpinned, previous: []Dependency{{ID: unpinned.ID("r"), Current: "stable", Digest: oldDigest}}},
+		{name: "previous scan failed", image: unpinned, previous: []Dependency{{ID: unpinned.ID("r"), Current: "latest", Digest: oldDigest, Error: "timeout"}}},
+		{name: "pinned digest moved", image: pinned, changed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u.RememberDigests(tt.previous)
+			dep := u.CheckImage(context.Background(), "r", tt.image)
+			if dep.Error != "" {
+				t.Fatal(dep.Error)
+			}
+			if dep.Digest != newDigest || dep.ContentChanged != tt.changed {
+				t.Errorf("got digest %s, content changed %v, want %s, %v", dep.Digest, dep.ContentChanged, newDigest, tt.changed)
+			}
+		})
+	}
+}
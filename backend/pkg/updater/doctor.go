@@ -0,0 +1,114 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+	"github.com/geniusdynamics/updater/backend/internal/git"
+)
+
+// Check is the outcome of one check of Doctor, it passed when Problem is
+// empty. Warnings don't prevent the updater from running.
+type Check struct {
+	Name    string
+	Problem string
+	// Remedy tells how to fix Problem
+	Remedy  string
+	Warning bool
+}
+
+// Failed reports whether the check found a problem that is not a warning
+func (c Check) Failed() bool {
+	return c.Problem != "" && !c.Warning
+}
+
+// Doctor checks the configuration file, the token of every owner, its clone
+// folder and clones, and the reachability of the registries
+func (u *Updater) Doctor(ctx context.Context) []Check {
+	checks := []Check{checkConfigFile()}
+	for _, o := range u.owners {
+		checks = append(checks, u.checkToken(ctx, o), checkFolder(o.client.TemporaryFolder))
+		dirs, err := o.client.LocalRepositories()
+		if err != nil {
+			continue
+		}
+		for _, dir := range dirs {
+			checks = append(checks, checkClone(dir))
+		}
+	}
+	for _, host := range u.images.Hosts() {
+		c := Check{Name: "registry " + host}
+		if err := u.images.Ping(ctx, host); err != nil {
+			c.Problem = err.Error()
+			c.Remedy = "check the network, proxy and CA settings, and the credentials configured for " + host
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+func checkConfigFile() Check {
+	c := Check{Name: "config file"}
+	if err := config.CheckFile(); err != nil {
+		c.Problem = err.Error()
+		c.Remedy = "fix the file named by CONFIG_FILE, or the selected profile"
+	}
+	return c
+}
+
+func (u *Updater) checkToken(ctx context.Context, o owner) Check {
+	c := Check{Name: "token"}
+	if o.cfg.Name() != "" {
+		c.Name += " of " + o.cfg.Name()
+	}
+	status, err := o.client.CheckToken(ctx)
+	switch {
+	case errors.Is(err, ErrSSORequired):
+		c.Problem = err.Error()
+		c.Remedy = "authorize the token for the organization's single sign-on"
+	case err != nil:
+		c.Problem = err.Error()
+		c.Remedy = "check that GITHUB_TOKEN, the token_env of the owner or the GitHub App grant read access to the owner"
+	case status.Scopes != nil && !slices.Contains(status.Scopes, "repo"):
+		c.Problem = fmt.Sprintf("the token has no repo scope (scopes: %v)", status.Scopes)
+		c.Remedy = "grant the repo scope to scan private repositories"
+		c.Warning = true
+	case !status.Expiration.IsZero() && time.Until(status.Expiration) < u.cfg.TokenExpiryWarning:
+		c.Problem = "the token expires on " + status.Expiration.Format(time.DateOnly)
+		c.Remedy = "renew the token before it expires"
+		c.Warning = true
+	}
+	return c
+}
+
+func checkFolder(dir string) Check {
+	c := Check{Name: "clone folder " + dir}
+	f, err := os.CreateTemp(dir, ".doctor-")
+	if err != nil {
+		c.Problem = err.Error()
+		c.Remedy = "create the folder or point TEMPORARY_FOLDER, or the owner's temporary_folder, to a writable one"
+		return c
+	}
+	f.Close()
+	_ = os.Remove(f.Name())
+	return c
+}
+
+func checkClone(dir string) Check {
+	c := Check{Name: "clone " + dir}
+	clean, err := git.IsClean(dir)
+	switch {
+	case err != nil:
+		c.Problem = err.Error()
+		c.Remedy = "remove the clone, the next scan clones it again"
+	case !clean:
+		c.Problem = "the clone has local changes, it is not updated nor scanned"
+		c.Remedy = "commit, stash or discard the changes, or remove the clone"
+	}
+	return c
+}
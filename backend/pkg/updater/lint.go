@@ -0,0 +1,20 @@
+package updater
+
+import (
+	"github.com/geniusdynamics/updater/backend/internal/images"
+)
+
+// PinProblems returns why the pin of image should not be committed: a tag
+// known to be bad or latest without a digest. It only uses the configuration,
+// no registry is contacted.
+func (u *Updater) PinProblems(image Image) []string {
+	var problems []string
+	if image.Tag == "latest" && image.Digest == "" {
+		problems = append(problems, "unpinned latest tag")
+	}
+	tags := images.ParseVersions(u.images.SchemeFor(image.Name()), []Tag{{Name: image.Tag}})
+	if _, quarantined := u.images.FilterQuarantined(image.Name(), tags); len(quarantined) > 0 {
+		problems = append(problems, "quarantined "+quarantined[0].String())
+	}
+	return problems
+}
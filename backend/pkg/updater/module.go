@@ -0,0 +1,84 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/images"
+	"github.com/geniusdynamics/updater/backend/internal/report"
+	"github.com/google/go-github/v81/github"
+)
+
+// ModuleImage returns the image NethServer 8 publishes for repo, e.g.
+// ghcr.io/nethserver/mail for nethserver/ns8-mail
+func ModuleImage(repo *github.Repository) string {
+	return fmt.Sprintf("ghcr.io/%s/%s", strings.ToLower(repo.GetOwner().GetLogin()), strings.TrimPrefix(repo.GetName(), "ns8-"))
+}
+
+// CheckModule returns the latest published version of the module image of
+// repo and its publish date as a dependency of kind KindModule. When the
+// scanned files changed on the default branch after that date, the release
+// is pending since the oldest of those changes.
+func (u *Updater) CheckModule(ctx context.Context, repo *github.Repository) Dependency {
+	image := ModuleImage(repo)
+	registry, name := splitImage(image)
+	dep := Dependency{
+		Kind:       report.KindModule,
+		ID:         image,
+		Repository: repo.GetFullName(),
+		Image:      image,
+		Groups:     u.cfg.RepositoryGroups(repo.GetName()),
+	}
+
+	tags, err := u.images.ListTags(ctx, registry, name)
+	err = u.truncated(image, err, &dep)
+	if err == nil {
+		if sorted := images.SortByVersion(tags); len(sorted) > 0 {
+			dep.Latest = sorted[0].Name
+			var details images.TagDetails
+			details, err = u.images.ResolveTag(ctx, registry, name, dep.Latest)
+			if err == nil && !details.Created.IsZero() {
+				dep.Published = details.Created.UTC().Format(time.RFC3339)
+				err = u.checkPendingRelease(ctx, repo, details.Created, &dep)
+			}
+		}
+	}
+	if err != nil {
+		u.logf("Error getting the published versions of %s: %s", image, err)
+		dep.Error = err.Error()
+	}
+	return dep
+}
+
+// checkPendingRelease sets PendingSince to the date of the oldest commit
+// touching the scanned files after published. NS8 modules keep
+// build-images.sh at the repository root, which is where the files are looked
+// up, glob patterns are skipped as the commits can't be filtered by them.
+func (u *Updater) checkPendingRelease(ctx context.Context, repo *github.Repository, published time.Time, dep *Dependency) error {
+	client, err := u.clientFor(repo)
+	if err != nil {
+		return err
+	}
+	var oldest time.Time
+	for fileName := range u.FileNames {
+		if strings.ContainsAny(fileName, "*?[") {
+			continue
+		}
+		commits, err := client.CommitsSince(ctx, repo, fileName, published)
+		if err != nil {
+			return err
+		}
+		for _, c := range commits {
+			date := c.GetCommit().GetCommitter().GetDate().Time
+			if date.After(published) && (oldest.IsZero() || date.Before(oldest)) {
+				oldest = date
+			}
+		}
+	}
+	if !oldest.IsZero() {
+		dep.PendingSince = oldest.UTC().Format(time.RFC3339)
+	}
+	return nil
+}
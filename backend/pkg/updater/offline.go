@@ -0,0 +1,35 @@
+package updater
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/v81/github"
+)
+
+// LocalRepositories returns the repositories matching search that have a
+// local clone, left by sync or a previous run, without contacting GitHub.
+// Only the names and owner of the returned repositories are set.
+func (u *Updater) LocalRepositories(search string) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	for _, o := range u.owners {
+		dirs, err := o.client.LocalRepositories()
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			base := filepath.Base(dir)
+			name := strings.TrimSuffix(base, ".git")
+			if !strings.Contains(name, search) || !o.cfg.Matches(name) {
+				continue
+			}
+			repos = append(repos, &github.Repository{
+				Name:     github.Ptr(name),
+				FullName: github.Ptr(o.cfg.Name() + "/" + name),
+				Owner:    &github.User{Login: github.Ptr(o.cfg.Name())},
+				CloneURL: github.Ptr(base),
+			})
+		}
+	}
+	return repos, nil
+}
@@ -0,0 +1,75 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/files"
+	"github.com/geniusdynamics/updater/backend/internal/images"
+	"github.com/geniusdynamics/updater/backend/internal/report"
+)
+
+// Risk weights, the score is capped at 100
+const (
+	riskMajor        = 50
+	riskMinor        = 20
+	riskPatch        = 5
+	riskUnknownJump  = 30
+	riskFreshRelease = 20
+	riskUnsigned     = 10
+	riskQuarantined  = 10
+)
+
+// freshRelease is the age under which a release is considered too recent to
+// have been battle tested
+const freshRelease = 7 * 24 * time.Hour
+
+// scoreRisk estimates how risky the proposed update of dep is from the size of
+// the version jump, the age of the release, its signature and whether nearby
+// versions are quarantined. The publish date is looked up from the registry,
+// a failed lookup leaves that signal out.
+func (u *Updater) scoreRisk(ctx context.Context, image files.DockerImage, scheme images.VersionScheme, dep *report.Dependency) {
+	if !dep.Outdated() || dep.Latest == "" {
+		return
+	}
+	score := 0
+	var factors []string
+	add := func(points int, factor string) {
+		score += points
+		factors = append(factors, factor)
+	}
+
+	switch level := images.ChangeLevel(scheme(dep.Current), scheme(dep.Latest)); level {
+	case images.ChangeMajor:
+		add(riskMajor, "major version")
+	case images.ChangeMinor:
+		add(riskMinor, "minor version")
+	case images.ChangePatch:
+		add(riskPatch, "patch version")
+	default:
+		add(riskUnknownJump, "versions not comparable")
+	}
+
+	// the listing may already tell when the tag was pushed
+	created, _ := time.Parse(time.RFC3339, dep.LatestPublished)
+	if created.IsZero() {
+		if details, err := u.images.ResolveTag(ctx, image.Registry, image.Repo, dep.Latest); err == nil {
+			created = details.Created
+		}
+	}
+	if !created.IsZero() {
+		if age := time.Since(created); age < freshRelease {
+			add(riskFreshRelease, fmt.Sprintf("released %d days ago", int(age.Hours()/24)))
+		}
+	}
+	if dep.Signed != nil && !*dep.Signed {
+		add(riskUnsigned, "unsigned")
+	}
+	if len(dep.Quarantined) > 0 {
+		add(riskQuarantined, "newer versions quarantined")
+	}
+
+	dep.Risk = min(score, 100)
+	dep.RiskFactors = factors
+}
@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v81/github"
+)
+
+// redirect sends every request to the test server
+type redirect struct{ target *url.URL }
+
+func (t redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestScanRepositoryUnreadableFile(t *testing.T) {
+	content := base64.StdEncoding.EncodeToString([]byte(`postgres_image="docker.io/library/postgres:15.4"`))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/ns8-mail/git/trees/main":
+			w.Write([]byte(`{"tree":[{"path":"broken/build-images.sh","type":"blob"},{"path":"build-images.sh","type":"blob"}]}`))
+		case "/repos/org/ns8-mail/contents/build-images.sh":
+			w.Write([]byte(`{"type":"file","encoding":"base64","content":"` + content + `"}`))
+		default:
+			http.Error(w, `{"message":"Server Error"}`, http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+	org := "org"
+	u, err := New(context.Background(), &Config{
+		GitHubClient: &http.Client{Transport: redirect{target}},
+		Organization: &org,
+		ScanPatterns: []string{"build-images.sh"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &github.Repository{Name: github.Ptr("ns8-mail"), FullName: github.Ptr("org/ns8-mail"), DefaultBranch: github.Ptr("main"), Owner: &github.User{Login: &org}}
+
+	images, err := u.ScanRepository(context.Background(), repo, true)
+	if !errors.Is(err, ErrUnreadableFiles) {
+		t.Fatalf("got %v, want ErrUnreadableFiles", err)
+	}
+	if len(images) != 1 || images[0].Tag != "15.4" {
+		t.Errorf("got %+v, want the image of the readable file", images)
+	}
+	if d := u.Diagnostics; len(d) != 2 || d[0].File != "broken/build-images.sh" || d[0].Skipped == "" {
+		t.Errorf("unreadable file not recorded: %+v", d)
+	}
+}
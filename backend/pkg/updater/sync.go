@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-github/v81/github"
+)
+
+// SyncSummary tells what Sync did, repositories are named by full name and
+// local clones by path
+type SyncSummary struct {
+	Cloned  []string
+	Pulled  []string
+	Removed []string
+	// Stale are the clones whose repository was archived, deleted or no
+	// longer matches, removed only when pruning
+	Stale  []string
+	Failed map[string]error
+}
+
+// Sync reconciles the local clones of every owner with the repositories
+// matching search: new ones are cloned, existing ones pulled, and clones of
+// archived or vanished repositories are reported as stale, or removed when
+// prune is set.
+func (u *Updater) Sync(ctx context.Context, search string, prune bool) (*SyncSummary, error) {
+	summary := &SyncSummary{Failed: map[string]error{}}
+
+	for _, o := range u.owners {
+		found, err := u.ownerRepositories(ctx, o, search)
+		if err != nil {
+			return nil, err
+		}
+		wanted := map[string]*github.Repository{}
+		for _, repo := range found {
+			if !repo.GetArchived() {
+				wanted[o.client.ClonePath(repo.GetCloneURL())] = repo
+			}
+		}
+
+		local, err := o.client.LocalRepositories()
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range local {
+			if _, ok := wanted[dir]; ok {
+				continue
+			}
+			if !prune {
+				summary.Stale = append(summary.Stale, dir)
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				summary.Failed[dir] = err
+				continue
+			}
+			summary.Removed = append(summary.Removed, dir)
+		}
+
+		for dir, repo := range wanted {
+			if err := ctx.Err(); err != nil {
+				return summary, err
+			}
+			if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+				if err := o.client.PullRepository(ctx, dir, repo.GetDefaultBranch()); err != nil {
+					summary.Failed[repo.GetFullName()] = err
+					continue
+				}
+				summary.Pulled = append(summary.Pulled, repo.GetFullName())
+				continue
+			}
+			if _, err := o.client.CloneRepository(ctx, repo.GetCloneURL()); err != nil {
+				summary.Failed[repo.GetFullName()] = err
+				continue
+			}
+			summary.Cloned = append(summary.Cloned, repo.GetFullName())
+		}
+	}
+	return summary, nil
+}
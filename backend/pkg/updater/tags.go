@@ -0,0 +1,39 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/images"
+)
+
+// Tags lists the tags of image, e.g. docker.io/library/postgres, with the
+// versions parsed by its version scheme. Versioned tags come first, newest
+// first, followed by the others in registry order. When the listing stops
+// at the page limit the tags listed are returned with an error wrapping
+// ErrTagsTruncated.
+func (u *Updater) Tags(ctx context.Context, image string) ([]Tag, error) {
+	registry, repo := splitImage(image)
+	if repo == "" || !strings.Contains(registry, ".") {
+		return nil, fmt.Errorf("%q is not a full image name like docker.io/library/postgres", image)
+	}
+	tags, err := u.images.ListTags(ctx, registry, repo)
+	if err != nil && !errors.Is(err, ErrTagsTruncated) {
+		return nil, err
+	}
+	return images.SortTags(images.ParseVersions(u.images.SchemeFor(image), tags)), err
+}
+
+// TagCreated returns the build date of tag of image, zero when the registry
+// doesn't expose it
+func (u *Updater) TagCreated(ctx context.Context, image, tag string) (time.Time, error) {
+	registry, repo := splitImage(image)
+	details, err := u.images.ResolveTag(ctx, registry, repo, tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return details.Created, nil
+}
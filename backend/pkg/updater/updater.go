@@ -0,0 +1,701 @@
+// Package updater scans NethServer 8 module repositories for the container
+// images they reference and checks the registries for newer tags. It is the
+// library behind the ns8-updater command and can be embedded by dashboards or
+// other automation.
+//
+// Every Updater has its own registry clients and settings, a program may
+// create several with different configurations.
+//
+// # Compatibility
+//
+// The exported identifiers of this package and the JSON encoding of
+// Dependency form the v1 API. Within v1 they only change in backward
+// compatible ways: new functions, methods and optional fields may be added,
+// existing ones are neither removed, renamed nor given a different meaning,
+// and JSON field names stay as they are. The json report wraps the
+// dependencies in an envelope carrying its schema_version, which is only
+// increased on incompatible changes. Everything under internal/ may change at
+// any time.
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/internal/config"
+	"github.com/geniusdynamics/updater/backend/internal/events"
+	"github.com/geniusdynamics/updater/backend/internal/files"
+	"github.com/geniusdynamics/updater/backend/internal/git"
+	"github.com/geniusdynamics/updater/backend/internal/images"
+	"github.com/geniusdynamics/updater/backend/internal/report"
+	"github.com/google/go-github/v81/github"
+)
+
+type (
+	// Config holds the updater settings, see NewConfig
+	Config = config.Config
+	// RegistryConfig describes a private or self-hosted registry
+	RegistryConfig = config.RegistryConfig
+	// Image is a container image reference found in a repository
+	Image = files.DockerImage
+	// Dependency is an image together with the outcome of its update check
+	Dependency = report.Dependency
+	// Envelope is the json report, the dependencies with the schema version
+	Envelope = report.Envelope
+	// Tag is a registry tag with its parsed version
+	Tag = images.Tag
+	// Diagnostic tells what scanning a single file yielded
+	Diagnostic = files.Diagnostic
+	// Event is something that happened during a check, see Updater.Events
+	Event = events.Event
+	// EventBus delivers events to its subscribers
+	EventBus = events.Bus
+)
+
+// Types of the events published on Updater.Events
+const (
+	EventRepositoryStarted = events.RepositoryStarted
+	EventRepositoryScanned = events.RepositoryScanned
+	EventDependencyChecked = events.DependencyChecked
+	EventRepositoryDone    = events.RepositoryDone
+	EventFailed            = events.Failed
+)
+
+var (
+	// ErrScanBudgetExceeded is returned with partial results when a
+	// repository scan runs out of time or files
+	ErrScanBudgetExceeded = files.ErrScanBudgetExceeded
+	// ErrUnreadableFiles is returned with partial results when some files
+	// of a remote scan could not be read
+	ErrUnreadableFiles = files.ErrUnreadableFiles
+	// ErrUnsupportedRegistry is reported for images on unknown registries
+	ErrUnsupportedRegistry = images.ErrUnsupportedRegistry
+	// ErrTagsTruncated is returned with the tags listed so far when a Docker
+	// Hub listing stops at DOCKERHUB_MAX_PAGES
+	ErrTagsTruncated = images.ErrTagsTruncated
+	// ErrDirtyWorktree is returned when an existing clone has local changes,
+	// it is left untouched and not scanned
+	ErrDirtyWorktree = git.ErrDirtyWorktree
+	// ErrSSORequired is returned when the token is not authorized for the
+	// SAML single sign-on of an organization, which is then skipped
+	ErrSSORequired = git.ErrSSORequired
+)
+
+// LoadConfig reads the configuration from the environment and the optional
+// CONFIG_FILE, with the profile named by NS8_UPDATER_PROFILE applied
+func LoadConfig() (*Config, error) {
+	return config.NewConfig()
+}
+
+// NewConfig is LoadConfig exiting the program when the configuration can't
+// be loaded.
+//
+// Deprecated: use LoadConfig, which returns the error instead.
+func NewConfig() *Config {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
+
+// Updater scans repositories and checks their images for updates
+type Updater struct {
+	cfg    *Config
+	owners []owner
+	// images looks up the registries with the settings of cfg, scanner
+	// recognizes the images of its registries
+	images  *images.Client
+	scanner *files.Scanner
+	// mu guards Diagnostics, repositories may be checked concurrently
+	mu sync.Mutex
+	// FileNames are the base names, or glob patterns like *.containerfile, of
+	// the scanned files
+	FileNames map[string]bool
+	// Logger receives progress messages, nil discards them
+	Logger *log.Logger
+	// Diagnostics collects what scanning every matching file yielded
+	Diagnostics []Diagnostic
+	// CheckModules adds the latest published module image of every checked
+	// repository to the results
+	CheckModules bool
+	// Bundle, when set, resolves targets from an exported bundle instead of
+	// querying the registries
+	Bundle *Bundle
+	// Offline scans the local clones as they are, without pulling, cloning
+	// or reading files through the GitHub API
+	Offline bool
+	// Diffs adds to outdated dependencies the diff their update would make
+	Diffs bool
+	// Events receives the progress of Check, subscribe to it to follow a
+	// run, e.g. to display its progress
+	Events *EventBus
+	// Pin proposes to pin images referenced by latest or a placeholder to
+	// the concrete version they currently point to
+	Pin bool
+
+	// knownDigests maps dependency IDs and tags to the digest the tag
+	// resolved to on a previous scan, see RememberDigests
+	knownDigests map[string]string
+}
+
+// New returns an Updater with its own registry clients configured by cfg,
+// scanning the files matching its scan patterns
+func New(ctx context.Context, cfg *Config) (*Updater, error) {
+	client, err := images.NewClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	registryHosts := make([]string, 0, len(cfg.Registries))
+	for _, r := range cfg.Registries {
+		registryHosts = append(registryHosts, r.Host)
+	}
+
+	u := &Updater{
+		cfg:          cfg,
+		images:       client,
+		scanner:      files.NewScanner(registryHosts),
+		FileNames:    map[string]bool{},
+		Events:       &EventBus{},
+		CheckModules: cfg.CheckModules,
+	}
+	for _, pattern := range cfg.ScanPatterns {
+		u.FileNames[pattern] = true
+	}
+	for _, o := range cfg.OwnerConfigs() {
+		u.owners = append(u.owners, owner{cfg: o, client: git.NewGitHubClient(cfg.ForOwner(o))})
+	}
+	return u, nil
+}
+
+// owner is a scanned organization or user with its own client
+type owner struct {
+	cfg    config.OwnerConfig
+	client *git.GitHubClient
+}
+
+// clientFor returns the client of the owner of repo
+func (u *Updater) clientFor(repo *github.Repository) (*git.GitHubClient, error) {
+	login := repo.GetOwner().GetLogin()
+	for _, o := range u.owners {
+		if strings.EqualFold(o.cfg.Name(), login) {
+			return o.client, nil
+		}
+	}
+	if len(u.owners) == 1 {
+		return u.owners[0].client, nil
+	}
+	return nil, fmt.Errorf("%s is not owned by a configured organization or user", repo.GetFullName())
+}
+
+func (u *Updater) logf(format string, args ...any) {
+	if u.Logger != nil {
+		u.Logger.Printf(format, args...)
+	}
+}
+
+func (u *Updater) scanOptions() files.ScanOptions {
+	opts := files.ScanOptions{
+		Timeout:     u.cfg.ScanTimeout,
+		MaxFiles:    u.cfg.ScanMaxFiles,
+		MaxFileSize: u.cfg.ScanMaxFileSize,
+		IgnoreDirs:  u.cfg.IgnoreDirs,
+		Scanner:     u.scanner,
+	}
+	if u.cfg.Debug {
+		opts.Logger = u.Logger
+	}
+	return opts
+}
+
+// ScanContent returns the images referenced in data, the content of a file
+// named fileName
+func (u *Updater) ScanContent(fileName string, data []byte) []Image {
+	return u.scanner.ScanContent(fileName, data)
+}
+
+// Repositories returns the repositories of every configured organization and
+// user whose name matches search and the owner's patterns
+func (u *Updater) Repositories(ctx context.Context, search string) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	for _, o := range u.owners {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		found, err := u.ownerRepositories(ctx, o, search)
+		if errors.Is(err, ErrSSORequired) {
+			// the other owners can still be scanned
+			u.logf("Skipping %s: %s", o.cfg.Name(), err)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", o.cfg.Name(), err)
+		}
+		u.warnTokenExpiry(o)
+		repos = append(repos, found...)
+	}
+	return repos, nil
+}
+
+// ownerRepositories returns the selected repositories of o. With configured
+// repository selectors every repository of o is listed and matched against
+// them, otherwise the repositories are searched by name.
+func (u *Updater) ownerRepositories(ctx context.Context, o owner, search string) ([]*github.Repository, error) {
+	var found []*github.Repository
+	if u.cfg.RepoSelectors.IsZero() {
+		result, err := o.client.SearchRepositories(ctx, search)
+		if err != nil {
+			return nil, err
+		}
+		found = result.Repositories
+	} else {
+		all, err := o.client.GetRepositories(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range all {
+			if u.cfg.RepoSelectors.Selects(repo.GetName(), repo.Topics) {
+				found = append(found, repo)
+			}
+		}
+	}
+	return slices.DeleteFunc(found, func(repo *github.Repository) bool { return !u.selects(o, repo) }), nil
+}
+
+// selects reports whether repo of o matches its patterns and is not an
+// archived, forked or template repository left out by the configuration
+func (u *Updater) selects(o owner, repo *github.Repository) bool {
+	switch {
+	case repo.GetArchived() && !u.cfg.IncludeArchived:
+		return false
+	case repo.GetFork() && !u.cfg.IncludeForks:
+		return false
+	case repo.GetIsTemplate() && !u.cfg.IncludeTemplates:
+		return false
+	}
+	return o.cfg.Matches(repo.GetName())
+}
+
+// warnTokenExpiry logs a warning when the token of o expires within the
+// configured TokenExpiryWarning
+func (u *Updater) warnTokenExpiry(o owner) {
+	expiration := o.client.TokenExpiration
+	if expiration.IsZero() || u.cfg.TokenExpiryWarning <= 0 {
+		return
+	}
+	if time.Until(expiration) < u.cfg.TokenExpiryWarning {
+		u.logf("Warning: the GitHub token of %s expires on %s", o.cfg.Name(), expiration.Format(time.DateOnly))
+	}
+}
+
+// Check scans repo and checks every image found for updates, followed by the
+// latest published version of the repository's module image when CheckModules
+// is set. Lookup failures are recorded in the dependencies, the error is only
+// set when the scan itself fails; on ErrScanBudgetExceeded and
+// ErrUnreadableFiles the dependencies found so far are returned flagged as
+// partial. Archived repositories, when included, are not scanned, a single
+// dependency marked as archived is returned for them.
+func (u *Updater) Check(ctx context.Context, repo *github.Repository, remote bool) ([]Dependency, error) {
+	if repo.GetArchived() {
+		return []Dependency{{
+			ID:         repo.GetFullName(),
+			Repository: repo.GetFullName(),
+			Groups:     u.cfg.RepositoryGroups(repo.GetName()),
+			Topics:     repo.Topics,
+			Archived:   true,
+		}}, nil
+	}
+	name := repo.GetFullName()
+	u.Events.Publish(Event{Type: events.RepositoryStarted, Repository: name})
+	dockerImages, scanErr := u.ScanRepository(ctx, repo, remote)
+	partial := errors.Is(scanErr, ErrScanBudgetExceeded) || errors.Is(scanErr, ErrUnreadableFiles)
+	if scanErr != nil && !partial {
+		u.Events.Publish(Event{Type: events.Failed, Repository: name, Err: scanErr})
+		return nil, scanErr
+	}
+	u.Events.Publish(Event{Type: events.RepositoryScanned, Repository: name, Images: len(dockerImages)})
+
+	var dependencies []Dependency
+	// an imported bundle means the registries are out of reach
+	if u.CheckModules && u.Bundle == nil && !u.Offline {
+		dep := u.CheckModule(ctx, repo)
+		u.Events.Publish(Event{Type: events.DependencyChecked, Repository: name, Dependency: &dep})
+		dependencies = append(dependencies, dep)
+	}
+	for _, image := range dockerImages {
+		if err := ctx.Err(); err != nil {
+			return dependencies, err
+		}
+		if u.cfg.ExcludesImage(repo.GetName(), image.Name()) {
+			u.logf("Skipping excluded image %s in %s", image.Name(), repo.GetFullName())
+			continue
+		}
+		dep := u.CheckImage(ctx, repo.GetFullName(), image)
+		dep.Groups = u.cfg.RepositoryGroups(repo.GetName())
+		dep.Topics = repo.Topics
+		dep.Partial = partial
+		if hold, ok := u.cfg.Hold(repo.GetName(), image.Name(), image.Tag); ok {
+			dep.Held = hold.String()
+		}
+		u.Events.Publish(Event{Type: events.DependencyChecked, Repository: name, Dependency: &dep})
+		dependencies = append(dependencies, dep)
+	}
+	if u.Diffs {
+		if err := u.addDiffs(ctx, repo, remote, dependencies); err != nil {
+			u.logf("Error computing the diffs of %s: %s", repo.GetFullName(), err)
+		}
+	}
+	u.Events.Publish(Event{Type: events.RepositoryDone, Repository: name})
+	return dependencies, scanErr
+}
+
+// projectDir returns the directory of file, each directory holding scanned
+// files being a project of its own, or an empty string at the root
+func projectDir(file string) string {
+	if dir := path.Dir(file); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// CheckImage looks up the updates of an image found in repository, within
+// the configured lookup timeout
+func (u *Updater) CheckImage(ctx context.Context, repository string, image Image) Dependency {
+	dep := Dependency{
+		ID:         image.ID(repository),
+		Repository: repository,
+		Project:    projectDir(image.File),
+		File:       image.File,
+		Image:      image.Name(),
+		Current:    image.Tag,
+		Variables:  image.Variables,
+		Line:       image.Line,
+		Column:     image.Column,
+		Snippet:    image.Snippet,
+	}
+	if u.cfg.LookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.cfg.LookupTimeout)
+		defer cancel()
+	}
+	err := ctx.Err()
+	if err == nil && u.Bundle != nil {
+		// images the bundle doesn't list were up to date when it was exported
+		dep.Latest = image.Tag
+		if entry, ok := u.Bundle.Lookup(dep.Image, image.Tag); ok {
+			dep.Latest = entry.Target
+			dep.Digest = entry.Digest
+		}
+	} else if err == nil && u.Offline {
+		err = errors.New("no bundle to resolve updates from offline")
+	} else if err == nil {
+		err = u.checkUpdate(ctx, image, &dep)
+	}
+	if u.cfg.CheckEOL && u.Bundle == nil && !u.Offline {
+		eol, ok, eolErr := u.images.EndOfLife(ctx, dep.Image, u.images.SchemeFor(dep.Image)(image.Tag))
+		if eolErr != nil {
+			u.logf("Error looking up the end of life of %s:%s: %s", dep.Image, image.Tag, eolErr)
+		} else if ok {
+			dep.EOL = eol.Format(time.DateOnly)
+			switch {
+			case time.Now().After(eol):
+				dep.EOLStatus = report.EOLReached
+			case time.Until(eol) < u.cfg.EOLWarning:
+				dep.EOLStatus = report.EOLSoon
+			}
+		}
+	}
+	if errors.Is(err, ErrUnsupportedRegistry) {
+		dep.Error = err.Error()
+		dep.Unsupported = true
+	} else if err != nil {
+		u.logf("Error getting updates for %s: %s", image.Repo, err)
+		dep.Error = err.Error()
+	}
+	return dep
+}
+
+// signedCandidates bounds the tags checked for a signature when an image
+// requires signed updates, and platformCandidates the manifests inspected
+// for the required platforms
+const (
+	signedCandidates   = 10
+	platformCandidates = 10
+)
+
+// checkUpdate fills in the latest tag of image, whether it is signed when
+// signatures are checked or required, the outcome of its verification and the
+// newer versions skipped because they are quarantined
+func (u *Updater) checkUpdate(ctx context.Context, image files.DockerImage, dep *report.Dependency) error {
+	cfg := u.cfg
+	scheme := u.images.SchemeFor(image.Name())
+	if u.Pin && scheme(image.Tag) == "" {
+		return u.checkPin(ctx, image, scheme, dep)
+	}
+	if cfg.CompareDigests && scheme(image.Tag) == "" {
+		return u.checkDigest(ctx, image, dep)
+	}
+	enough := u.images.NewerCandidates(image.Name(), image.Tag, scheme, cfg.TagCandidates)
+	filter := u.images.NameFilter(image.Name(), image.Tag, scheme(image.Tag))
+	tags, err := u.images.ListTagsNamed(ctx, image.Registry, image.Repo, filter, enough)
+	if err = u.truncated(image.Name(), err, dep); err != nil {
+		return err
+	}
+	tags = u.images.FilterVariant(image.Name(), image.Tag, images.ParseVersions(scheme, tags))
+	tags = u.images.FilterStream(image.Name(), scheme(image.Tag), tags)
+	tags, quarantined := u.images.FilterQuarantined(image.Name(), tags)
+	if len(cfg.Platforms) > 0 {
+		var incompatible []images.Tag
+		tags, incompatible = images.FilterPlatforms(tags, cfg.Platforms, scheme(image.Tag))
+		for _, t := range images.SortByVersion(incompatible) {
+			dep.IncompatiblePlatforms = append(dep.IncompatiblePlatforms, t.Name)
+		}
+	}
+
+	if cfg.RequiresSignature(image.Name()) {
+		latest, err := u.images.LatestSigned(ctx, image.Registry, image.Repo, tags, signedCandidates)
+		if err != nil {
+			return err
+		}
+		if latest == nil {
+			return fmt.Errorf("no signed tag among the %d newest tags", signedCandidates)
+		}
+		signed := true
+		dep.Latest = latest.Name
+		dep.Signed = &signed
+	} else if sorted := images.SortByVersion(tags); len(sorted) > 0 {
+		latest := &sorted[0]
+		if len(cfg.Platforms) > 0 {
+			// listings rarely tell the platforms, the manifests do
+			var incompatible []images.Tag
+			latest, incompatible, err = u.images.FirstPullable(ctx, image.Registry, image.Repo, sorted, scheme(image.Tag), cfg.Platforms, platformCandidates)
+			for _, t := range incompatible {
+				dep.IncompatiblePlatforms = append(dep.IncompatiblePlatforms, t.Name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if latest == nil {
+			// nothing newer is published for the required platforms
+			latest = &images.Tag{Name: image.Tag}
+		}
+		dep.Latest = latest.Name
+		if !latest.Published.IsZero() {
+			dep.LatestPublished = latest.Published.Format(time.RFC3339)
+		}
+		dep.LatestDigest = latest.Digest
+		dep.LatestPlatforms = latest.Platforms
+		if cfg.CheckSignatures && dep.Outdated() {
+			signed, err := u.images.IsSigned(ctx, image.Registry, image.Repo, dep.Latest)
+			if err != nil {
+				return err
+			}
+			dep.Signed = &signed
+		}
+	}
+
+	// tell when the upgrade we would have proposed is a known bad version
+	baseline := image.Tag
+	if dep.Latest != "" {
+		baseline = dep.Latest
+	}
+	for _, q := range images.NewerQuarantined(quarantined, scheme(baseline)) {
+		dep.Quarantined = append(dep.Quarantined, q.String())
+	}
+
+	// signed updates are picked without looking at their platforms
+	if cfg.RequiresSignature(image.Name()) && dep.Outdated() && len(cfg.Platforms) > 0 {
+		if err := u.images.CheckPullable(ctx, image.Registry, image.Repo, dep.Latest, cfg.Platforms); err != nil {
+			latest := dep.Latest
+			dep.Latest = ""
+			if errors.Is(err, images.ErrPlatformMissing) {
+				dep.IncompatiblePlatforms = append(dep.IncompatiblePlatforms, latest)
+			}
+			return fmt.Errorf("not proposing %s, it is not pullable: %w", latest, err)
+		}
+	}
+
+	if dep.Outdated() && u.images.RequiresVerification(image.Name()) {
+		verification, err := u.images.VerifyTag(ctx, image.Registry, image.Repo, dep.Latest)
+		if err != nil {
+			latest := dep.Latest
+			dep.Latest = ""
+			return fmt.Errorf("not proposing %s, signature verification failed: %w", latest, err)
+		}
+		dep.Verification = verification
+	}
+	u.scoreRisk(ctx, image, scheme, dep)
+	return nil
+}
+
+// checkPin proposes the newest version sharing the digest of the unpinned tag
+// of image, or the newest version when no digest tells them apart
+func (u *Updater) checkPin(ctx context.Context, image files.DockerImage, scheme images.VersionScheme, dep *report.Dependency) error {
+	dep.Unpinned = true
+	tags, err := u.images.ListTags(ctx, image.Registry, image.Repo)
+	if err = u.truncated(image.Name(), err, dep); err != nil {
+		return err
+	}
+	digest := ""
+	for _, t := range tags {
+		if t.Name == image.Tag {
+			digest = t.Digest
+		}
+	}
+	tags, _ = u.images.FilterQuarantined(image.Name(), images.ParseVersions(scheme, tags))
+	sorted := images.SortByVersion(tags)
+	if len(sorted) == 0 {
+		return fmt.Errorf("no versioned tag to pin %s to", image.Tag)
+	}
+	dep.Latest = sorted[0].Name
+	for _, t := range sorted {
+		if digest != "" && t.Digest == digest {
+			dep.Latest = t.Name
+			break
+		}
+	}
+	return nil
+}
+
+// RememberDigests records the digests the tags of deps resolved to, usually
+// on the previous scan, so that a content change behind an unpinned tag like
+// latest is flagged when CompareDigests is set
+func (u *Updater) RememberDigests(deps []Dependency) {
+	u.knownDigests = make(map[string]string, len(deps))
+	for _, d := range deps {
+		if d.Digest != "" && d.Error == "" && d.Kind != report.KindModule {
+			u.knownDigests[d.ID+"\x00"+d.Current] = d.Digest
+		}
+	}
+}
+
+// truncated flags dep when err tells that the tag listing of image stopped
+// at the page limit, which is not a failure, and returns the other errors
+func (u *Updater) truncated(image string, err error, dep *report.Dependency) error {
+	if !errors.Is(err, ErrTagsTruncated) {
+		return err
+	}
+	u.logf("Newer tags of %s may be missing: %s", image, err)
+	dep.TagsTruncated = true
+	return nil
+}
+
+// checkDigest resolves what the tag of image points to now, and flags a
+// content change when the reference pins another digest or, unpinned, when
+// the tag pointed to another one on the remembered scan
+func (u *Updater) checkDigest(ctx context.Context, image files.DockerImage, dep *report.Dependency) error {
+	details, err := u.images.ResolveTag(ctx, image.Registry, image.Repo, image.Tag)
+	if err != nil {
+		return err
+	}
+	dep.Digest = details.Digest
+	if !details.Created.IsZero() {
+		dep.Published = details.Created.UTC().Format(time.RFC3339)
+	}
+	known := image.Digest
+	if known == "" {
+		known = u.knownDigests[dep.ID+"\x00"+image.Tag]
+	}
+	dep.ContentChanged = known != "" && known != details.Digest
+	return nil
+}
+
+// ScanRepository returns the docker images referenced in repo, either from a
+// fresh clone or, in remote mode, straight from the GitHub API. When the scan
+// budget runs out the images found so far are returned with an error wrapping
+// ErrScanBudgetExceeded, and those of the readable files with an error
+// wrapping ErrUnreadableFiles when a remote file can't be read.
+func (u *Updater) ScanRepository(ctx context.Context, repo *github.Repository, remote bool) ([]Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	client, err := u.clientFor(repo)
+	if err != nil {
+		return nil, err
+	}
+	opts := u.scanOptions()
+	opts.Diagnose = func(d files.Diagnostic) {
+		d.Repository = repo.GetFullName()
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.Diagnostics = append(u.Diagnostics, d)
+	}
+	if remote && u.Offline {
+		return nil, fmt.Errorf("%s: remote scans are not possible offline", repo.GetFullName())
+	}
+	if remote {
+		entries, err := client.FindFiles(ctx, repo, u.FileNames)
+		if err != nil {
+			return nil, err
+		}
+		entries = slices.DeleteFunc(entries, func(e *github.TreeEntry) bool {
+			return files.InIgnoredDir(opts.IgnoreDirs, e.GetPath())
+		})
+		u.logf("Github Repo: %s (remote, %d files)", repo.GetFullName(), len(entries))
+		budget := files.NewBudget(opts)
+		var dockerImages []Image
+		var unreadable []string
+		for _, entry := range entries {
+			if err := budget.Spend(); err != nil {
+				return dockerImages, err
+			}
+			p := entry.GetPath()
+			if opts.TooLarge(int64(entry.GetSize())) {
+				opts.Debugf("skipping %s/%s: %d bytes exceeds the %d bytes limit", repo.GetFullName(), p, entry.GetSize(), opts.MaxFileSize)
+				opts.Record(p, 0, fmt.Sprintf("%d bytes exceeds the %d bytes limit", entry.GetSize(), opts.MaxFileSize))
+				continue
+			}
+			data, err := client.ReadFile(ctx, repo, p)
+			if err != nil && ctx.Err() != nil {
+				return nil, err
+			}
+			if err != nil {
+				opts.Debugf("skipping %s/%s: %s", repo.GetFullName(), p, err)
+				opts.Record(p, 0, err.Error())
+				unreadable = append(unreadable, fmt.Sprintf("%s: %s", p, err))
+				continue
+			}
+			if files.IsBinary(data) {
+				opts.Debugf("skipping %s/%s: binary file", repo.GetFullName(), p)
+				opts.Record(p, 0, "binary file")
+				continue
+			}
+			found := u.scanner.ScanContent(p, data)
+			opts.Record(p, len(found), "")
+			dockerImages = append(dockerImages, found...)
+		}
+		if len(unreadable) > 0 {
+			return dockerImages, fmt.Errorf("%w: %s", ErrUnreadableFiles, strings.Join(unreadable, ", "))
+		}
+		return dockerImages, nil
+	}
+
+	// reuse clones left by sync or a previous run
+	dir := client.ClonePath(repo.GetCloneURL())
+	_, statErr := os.Stat(filepath.Join(dir, ".git"))
+	switch {
+	case u.Offline && statErr != nil:
+		return nil, fmt.Errorf("no local clone of %s to scan offline", repo.GetFullName())
+	case u.Offline:
+		// scanned as is
+	case statErr == nil:
+		if err := client.PullRepository(ctx, dir, repo.GetDefaultBranch()); err != nil {
+			return nil, err
+		}
+	default:
+		if dir, err = client.CloneRepository(ctx, repo.GetCloneURL()); err != nil {
+			return nil, err
+		}
+	}
+	u.logf("Github Repo: %s", dir)
+	return files.FindDockerImages(ctx, dir, u.FileNames, opts)
+}
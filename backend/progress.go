@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/geniusdynamics/updater/backend/pkg/updater"
+)
+
+// isTerminal reports whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// showProgress keeps a status line of the checks of u up to date on stderr
+// until the returned function is called
+func showProgress(u *updater.Updater) (stop func()) {
+	var mu sync.Mutex
+	repos, images, checked, outdated := 0, 0, 0, 0
+	current := ""
+	render := func() {
+		fmt.Fprintf(os.Stderr, "\r\033[K%d repositories, %d/%d images checked, %d outdated %s", repos, checked, images, outdated, current)
+	}
+	unsubscribe := u.Events.Subscribe(func(e updater.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch e.Type {
+		case updater.EventRepositoryStarted:
+			repos++
+			current = e.Repository
+		case updater.EventRepositoryScanned:
+			images += e.Images
+		case updater.EventDependencyChecked:
+			if e.Dependency.Kind == "" {
+				checked++
+			}
+			if e.Dependency.Outdated() {
+				outdated++
+			}
+		case updater.EventRepositoryDone, updater.EventFailed:
+			current = ""
+		}
+		render()
+	})
+	return func() {
+		unsubscribe()
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/geniusdynamics/updater/backend/internal/report"
+)
+
+// runReport renders the results of a previous scan, saved with -format json
+// or as the SCAN_STATE, in another format without scanning again
+func runReport(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", report.FormatMarkdown, "output format: "+strings.Join(report.Formats, ", "))
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: report [flags] file, the json report of a scan, - reads it from stdin")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if !report.IsSupported(*format) {
+		log.Fatalf("unknown format %q, expected one of: %s", *format, strings.Join(report.Formats, ", "))
+	}
+
+	fileName := flags.Arg(0)
+	var data []byte
+	var err error
+	if fileName == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fileName)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	deps, err := report.Parse(data)
+	if err != nil {
+		log.Fatalf("invalid report %s: %s", fileName, err)
+	}
+	if err := report.Render(os.Stdout, *format, deps); err != nil {
+		log.Fatal(err)
+	}
+}
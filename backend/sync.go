@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func runSync(args []string) {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	prune := flags.Bool("prune", false, "remove local clones whose repository was archived, deleted or no longer matches")
+	_ = flags.Parse(args)
+
+	ctx, _, _, u := setup()
+	summary, err := u.Sync(ctx, "ns8-", *prune)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, line := range []struct {
+		label string
+		repos []string
+	}{
+		{"cloned", summary.Cloned},
+		{"pulled", summary.Pulled},
+		{"removed", summary.Removed},
+		{"stale", summary.Stale},
+	} {
+		if len(line.repos) > 0 {
+			fmt.Printf("%s: %s\n", line.label, strings.Join(line.repos, ", "))
+		}
+	}
+	for repo, err := range summary.Failed {
+		fmt.Printf("failed: %s: %s\n", repo, err)
+	}
+	if !*prune && len(summary.Stale) > 0 {
+		fmt.Println("run with -prune to remove the stale clones")
+	}
+	if len(summary.Failed) > 0 {
+		os.Exit(1)
+	}
+}
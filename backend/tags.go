@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	"github.com/geniusdynamics/updater/backend/pkg/updater"
+)
+
+func runTags(args []string) {
+	flags := flag.NewFlagSet("tags", flag.ExitOnError)
+	filter := flags.String("filter", "", "only list the tags matching this regular expression")
+	dates := flags.Bool("dates", false, "look up the build date of every listed tag, one registry call each")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: tags [flags] registry/repository, e.g. tags docker.io/library/postgres")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	re, err := regexp.Compile(*filter)
+	if err != nil {
+		log.Fatalf("invalid filter: %s", err)
+	}
+
+	ctx, _, _, u := setup()
+	image := flags.Arg(0)
+	tags, err := u.Tags(ctx, image)
+	if errors.Is(err, updater.ErrTagsTruncated) {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, t := range tags {
+		if !re.MatchString(t.Name) {
+			continue
+		}
+		created := ""
+		if !t.Published.IsZero() {
+			created = t.Published.Format(time.DateOnly)
+		}
+		if *dates && created == "" {
+			date, err := u.TagCreated(ctx, image, t.Name)
+			if err != nil {
+				created = "error: " + err.Error()
+			} else if !date.IsZero() {
+				created = date.Format(time.DateOnly)
+			}
+		}
+		version := t.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, version, created)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}